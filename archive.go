@@ -0,0 +1,211 @@
+package main
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"crypto"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+)
+
+// Members of archives are named "archive!path"
+const archiveSeparator = "!"
+
+// Suffixes of supported archives & their decompressors
+var archiveSuffixes = []struct {
+	suffixes   []string
+	decompress func(io.Reader) (io.ReadCloser, error)
+}{
+	{[]string{".tar"}, func(r io.Reader) (io.ReadCloser, error) { return io.NopCloser(r), nil }},
+	{[]string{".tar.gz", ".tgz"}, gunzip},
+	{[]string{".tar.bz2", ".tbz2", ".tbz"}, bunzip2},
+	{[]string{".tar.xz", ".txz"}, unxz},
+	{[]string{".tar.zst", ".tzst"}, unzstd},
+	{[]string{".zip"}, nil},
+}
+
+func archiveDecompressor(name string) (func(io.Reader) (io.ReadCloser, error), bool) {
+	lower := strings.ToLower(name)
+	for _, archive := range archiveSuffixes {
+		if slices.ContainsFunc(archive.suffixes, func(suffix string) bool { return strings.HasSuffix(lower, suffix) }) {
+			return archive.decompress, true
+		}
+	}
+	return nil, false
+}
+
+func isArchive(name string) bool {
+	_, ok := archiveDecompressor(name)
+	return ok
+}
+
+// Stream the regular files of an archive in the order they're stored
+func walkArchive(name string, fn func(member string, size Size, r io.Reader) error) error {
+	decompress, _ := archiveDecompressor(name)
+	if decompress == nil {
+		z, err := zip.OpenReader(name)
+		if err != nil {
+			return err
+		}
+		defer z.Close()
+		for _, file := range z.File {
+			if !file.Mode().IsRegular() {
+				continue
+			}
+			r, err := file.Open()
+			if err != nil {
+				return fmt.Errorf("%s: %v", name, err)
+			}
+			err = fn(file.Name, Size(file.UncompressedSize64), r)
+			r.Close()
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	f, err := os.Open(name)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	r, err := decompress(f)
+	if err != nil {
+		return fmt.Errorf("%s: %v", name, err)
+	}
+	defer r.Close()
+	tr := tar.NewReader(r)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		} else if err != nil {
+			return fmt.Errorf("%s: %v", name, err)
+		}
+		if header.Typeflag != tar.TypeReg {
+			continue
+		}
+		if err := fn(header.Name, header.Size, tr); err != nil {
+			return err
+		}
+	}
+}
+
+// Hash all members of an archive with new checksums of the given algorithms
+func hashArchiveMembers(name string, hashes []crypto.Hash) ([]*Checksums, error) {
+	var members []*Checksums
+	err := walkArchive(name, func(member string, size Size, r io.Reader) error {
+		checksums := make([]*Checksum, len(hashes))
+		for i, h := range hashes {
+			checksums[i] = &Checksum{hash: h}
+		}
+		checksums, n := hashF(io.NopCloser(r), checksums)
+		if checksums == nil || n != size {
+			return fmt.Errorf("%s: error hashing %s", name, member)
+		}
+		members = append(members, &Checksums{
+			file:      name + archiveSeparator + member,
+			size:      n,
+			checksums: checksums,
+		})
+		return nil
+	})
+	return members, err
+}
+
+// Used by the --archive option
+func hashArchive(file string, checksums []*Checksum) (*Checksums, error) {
+	if !isArchive(file) {
+		return hashFile(file, checksums)
+	}
+	members, err := hashArchiveMembers(file, chosen)
+	if err != nil {
+		return nil, err
+	}
+	// Empty archives have no output
+	if members == nil {
+		members = []*Checksums{}
+	}
+	return &Checksums{file: file, pieces: members}, nil
+}
+
+// Split "archive!path" if the file doesn't exist & the archive does
+func splitMember(file string) (archive, member string) {
+	if !strings.Contains(file, archiveSeparator) {
+		return "", ""
+	}
+	if _, err := os.Lstat(file); err == nil {
+		return "", ""
+	}
+	for i := 0; i < len(file); i++ {
+		j := strings.Index(file[i:], archiveSeparator)
+		if j == -1 {
+			break
+		}
+		i += j
+		if isArchive(file[:i]) {
+			if info, err := os.Stat(file[:i]); err == nil && info.Mode().IsRegular() {
+				return file[:i], file[i+len(archiveSeparator):]
+			}
+		}
+	}
+	return "", ""
+}
+
+// Hashes of the members of archives referenced by -c, computed in a single pass
+type archiveSums struct {
+	once    sync.Once
+	members map[string]*Checksums
+	err     error
+}
+
+var archiveCache = struct {
+	sync.Mutex
+	archives map[string]*archiveSums
+}{archives: make(map[string]*archiveSums)}
+
+// Used by the -c option for lines referencing archive members
+func hashMember(archive, member string, checksums []*Checksum) (*Checksums, error) {
+	hashes := make([]crypto.Hash, len(checksums))
+	names := make([]string, len(checksums))
+	for i, checksum := range checksums {
+		hashes[i] = checksum.hash
+		names[i] = algorithms[checksum.hash].name
+	}
+	key := archive + "\x00" + strings.Join(names, ",")
+
+	archiveCache.Lock()
+	sums, ok := archiveCache.archives[key]
+	if !ok {
+		sums = new(archiveSums)
+		archiveCache.archives[key] = sums
+	}
+	archiveCache.Unlock()
+
+	sums.once.Do(func() {
+		var members []*Checksums
+		members, sums.err = hashArchiveMembers(archive, hashes)
+		sums.members = make(map[string]*Checksums, len(members))
+		for _, m := range members {
+			sums.members[m.file] = m
+		}
+	})
+	if sums.err != nil {
+		return nil, sums.err
+	}
+
+	file := archive + archiveSeparator + member
+	m, ok := sums.members[file]
+	if !ok {
+		return nil, fmt.Errorf("%s: no such member in %s", member, archive)
+	}
+	for i := range checksums {
+		checksums[i].sum = m.checksums[i].sum
+	}
+	return &Checksums{file: file, size: m.size, checksums: checksums}, nil
+}
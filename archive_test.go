@@ -0,0 +1,113 @@
+package main
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"crypto"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func writeArchives(t *testing.T, dir string, files map[string]string) {
+	f, err := os.Create(filepath.Join(dir, "test.tar.gz"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	gw := gzip.NewWriter(f)
+	tw := tar.NewWriter(gw)
+	if err := tw.WriteHeader(&tar.Header{Name: "dir/", Typeflag: tar.TypeDir, Mode: 0o755}); err != nil {
+		t.Fatal(err)
+	}
+	for name, data := range files {
+		if err := tw.WriteHeader(&tar.Header{Name: name, Typeflag: tar.TypeReg, Mode: 0o644, Size: int64(len(data))}); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := tw.Write([]byte(data)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, c := range []interface{ Close() error }{tw, gw, f} {
+		if err := c.Close(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	f, err = os.Create(filepath.Join(dir, "test.zip"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	zw := zip.NewWriter(f)
+	for name, data := range files {
+		w, err := zw.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := w.Write([]byte(data)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, c := range []interface{ Close() error }{zw, f} {
+		if err := c.Close(); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func Test_hashArchive(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"dir/a.txt": "hello\n",
+		"b.txt":     "",
+	}
+	writeArchives(t, dir, files)
+
+	oldChosen := chosen
+	defer func() { chosen = oldChosen }()
+	chosen = []crypto.Hash{crypto.SHA256}
+
+	xwant := map[string]string{
+		"dir/a.txt": "5891b5b522d5df086d0ff0b110fbd9d21bb4fc7163af34d08286a2e846f6be03",
+		"b.txt":     "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855",
+	}
+	for _, archive := range []string{"test.tar.gz", "test.zip"} {
+		archive = filepath.Join(dir, archive)
+		got, err := hashArchive(archive, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(got.pieces) != len(files) {
+			t.Fatalf("hashArchive(%q) got %d members; want %d", archive, len(got.pieces), len(files))
+		}
+		for _, member := range got.pieces {
+			name := member.file[len(archive+archiveSeparator):]
+			if sum := hex.EncodeToString(member.checksums[0].sum); sum != xwant[name] {
+				t.Errorf("hashArchive(%q) got %s for %s; want %s", archive, sum, name, xwant[name])
+			}
+		}
+
+		// As done by -c
+		for name, want := range xwant {
+			a, m := splitMember(archive + archiveSeparator + name)
+			if a != archive || m != name {
+				t.Fatalf("splitMember() got %q & %q", a, m)
+			}
+			got, err := hashMember(a, m, []*Checksum{{hash: crypto.SHA256}})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if sum := hex.EncodeToString(got.checksums[0].sum); sum != want {
+				t.Errorf("hashMember(%q, %q) got %s; want %s", a, m, sum, want)
+			}
+		}
+		if _, err := hashMember(archive, "missing", []*Checksum{{hash: crypto.SHA256}}); err == nil {
+			t.Errorf("hashMember(%q) on a missing member should fail", archive)
+		}
+	}
+
+	if a, _ := splitMember(filepath.Join(dir, "missing.tar!file")); a != "" {
+		t.Errorf("splitMember() on a missing archive got %q", a)
+	}
+}
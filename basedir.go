@@ -0,0 +1,51 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+)
+
+// Resolve a path read by -c against base after stripping its first strip components like patch -p.
+// Paths escaping base with ".." or absolute paths are refused unless allowed.
+func resolvePath(file, base string, strip int, allow bool) (string, error) {
+	if strip > 0 {
+		parts := strings.Split(filepath.ToSlash(file), "/")
+		if len(parts) <= strip {
+			return "", fmt.Errorf("%s: can't strip %d path components", file, strip)
+		}
+		file = filepath.FromSlash(strings.Join(parts[strip:], "/"))
+	}
+	if !filepath.IsLocal(file) {
+		if !allow {
+			return "", fmt.Errorf("%s: outside the base directory", file)
+		}
+		if filepath.IsAbs(file) {
+			return filepath.Clean(file), nil
+		}
+	}
+	return filepath.Join(base, file), nil
+}
+
+// Used by the --base-dir & --strip options to resolve the paths of lines read by -c, counting refused ones as unreadable
+func resolveLines(lines <-chan *Checksums, base string, strip int, allow bool, unreadable *atomic.Uint64) <-chan *Checksums {
+	files := make(chan *Checksums, chanSize)
+
+	go func() {
+		defer close(files)
+		for line := range lines {
+			file, err := resolvePath(line.file, base, strip, allow)
+			if err != nil {
+				log.Print(err)
+				unreadable.Add(1)
+				continue
+			}
+			line.file = file
+			files <- line
+		}
+	}()
+
+	return files
+}
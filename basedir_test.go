@@ -0,0 +1,39 @@
+package main
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func Test_resolvePath(t *testing.T) {
+	tests := []struct {
+		file  string
+		strip int
+		allow bool
+		want  string
+		err   bool
+	}{
+		{"sub/file", 0, false, "base/sub/file", false},
+		{"./sub/../file", 0, false, "base/file", false},
+		{"build/out/file", 2, false, "base/file", false},
+		{"/abs/file", 1, false, "base/abs/file", false},
+		{"file offset 0-9", 0, false, "base/file offset 0-9", false},
+		{"file", 1, false, "", true},
+		{"../file", 0, false, "", true},
+		{"sub/../../file", 0, false, "", true},
+		{"/abs/file", 0, false, "", true},
+		{"ok/../../file", 1, false, "", true},
+		{"../file", 0, true, "file", false},
+		{"/abs/file", 0, true, "/abs/file", false},
+	}
+	for _, tt := range tests {
+		got, err := resolvePath(tt.file, "base", tt.strip, tt.allow)
+		if tt.err {
+			if err == nil {
+				t.Errorf("resolvePath(%q, %d, %v) should fail; got %q", tt.file, tt.strip, tt.allow, got)
+			}
+		} else if err != nil || got != filepath.FromSlash(tt.want) {
+			t.Errorf("resolvePath(%q, %d, %v) got %q, %v; want %q", tt.file, tt.strip, tt.allow, got, err, tt.want)
+		}
+	}
+}
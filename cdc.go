@@ -0,0 +1,176 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"math/bits"
+	"os"
+	"strings"
+)
+
+// Gear table for the rolling hash of FastCDC, generated with SplitMix64
+var gear = func() (table [256]uint64) {
+	var state uint64
+	for i := range table {
+		state += 0x9e3779b97f4a7c15
+		z := state
+		z = (z ^ (z >> 30)) * 0xbf58476d1ce4e5b9
+		z = (z ^ (z >> 27)) * 0x94d049bb133111eb
+		table[i] = z ^ (z >> 31)
+	}
+	return table
+}()
+
+// Chunk sizes for content-defined chunking
+type cdcParams struct {
+	min, avg, max Size
+	maskS, maskL  uint64
+}
+
+// Parse MIN:AVG:MAX or just AVG with MIN as AVG/4 and MAX as AVG*4
+func parseCDC(str string) (*cdcParams, error) {
+	var sizes []Size
+	for _, s := range strings.Split(str, ":") {
+		size, err := parseSize(s)
+		if err != nil {
+			return nil, err
+		}
+		sizes = append(sizes, size)
+	}
+	var p cdcParams
+	switch len(sizes) {
+	case 1:
+		p.min, p.avg, p.max = sizes[0]/4, sizes[0], sizes[0]*4
+	case 3:
+		p.min, p.avg, p.max = sizes[0], sizes[1], sizes[2]
+	default:
+		return nil, fmt.Errorf("invalid chunk sizes: %s", str)
+	}
+	if p.min < 64 || p.min > p.avg || p.avg > p.max {
+		return nil, fmt.Errorf("invalid chunk sizes: %s", str)
+	}
+	// Normalized chunking: harder to cut before the average size, easier after
+	n := bits.Len64(uint64(p.avg)) - 1
+	p.maskS = ^uint64(0) << (64 - min(n+1, 63))
+	p.maskL = ^uint64(0) << (64 - max(n-1, 1))
+	return &p, nil
+}
+
+// Return the length of the next chunk
+func (p *cdcParams) cut(data []byte) int {
+	n := len(data)
+	if n <= int(p.min) {
+		return n
+	}
+	n = min(n, int(p.max))
+	normal := min(n, int(p.avg))
+	var fp uint64
+	i := int(p.min)
+	for ; i < normal; i++ {
+		fp = fp<<1 + gear[data[i]]
+		if fp&p.maskS == 0 {
+			return i + 1
+		}
+	}
+	for ; i < n; i++ {
+		fp = fp<<1 + gear[data[i]]
+		if fp&p.maskL == 0 {
+			return i + 1
+		}
+	}
+	return n
+}
+
+// Used by the --cdc option
+func hashChunks(file string, _ []*Checksum) (*Checksums, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+	if info.IsDir() {
+		return nil, fmt.Errorf("%s is a directory", file)
+	}
+
+	// Empty files have no chunks
+	if info.Size() == 0 {
+		checksums, _ := hashF(f, nil)
+		return &Checksums{file: file, checksums: checksums}, nil
+	}
+
+	results := &Checksums{file: file}
+	buf := make([]byte, opts.cdc.max)
+	var n int
+	var offset Size
+	eof := false
+	for {
+		if !eof {
+			m, err := io.ReadFull(f, buf[n:])
+			n += m
+			if err == io.EOF || err == io.ErrUnexpectedEOF {
+				eof = true
+			} else if err != nil {
+				return nil, err
+			}
+		}
+		if n == 0 {
+			break
+		}
+		length := opts.cdc.cut(buf[:n])
+		checksums, _ := hashF(io.NopCloser(bytes.NewReader(buf[:length])), nil)
+		if checksums == nil {
+			return nil, fmt.Errorf("%s: error hashing chunk at offset %d", file, offset)
+		}
+		results.pieces = append(results.pieces, &Checksums{
+			file:      pieceName(file, offset, offset+Size(length)-1),
+			size:      Size(length),
+			offset:    offset,
+			checksums: checksums,
+		})
+		offset += Size(length)
+		n = copy(buf, buf[length:n])
+	}
+	results.size = offset
+	return results, nil
+}
+
+// Track unique chunks across all inputs
+type cdcStats struct {
+	seen         map[string]bool
+	chunks       uint64
+	uniqueChunks uint64
+	bytes        Size
+	uniqueBytes  Size
+}
+
+func (stats *cdcStats) add(results *Checksums) {
+	if stats.seen == nil {
+		stats.seen = make(map[string]bool)
+	}
+	for _, chunk := range results.pieces {
+		stats.chunks++
+		stats.bytes += chunk.size
+		key := string(chunk.checksums[0].sum)
+		if !stats.seen[key] {
+			stats.seen[key] = true
+			stats.uniqueChunks++
+			stats.uniqueBytes += chunk.size
+		}
+	}
+}
+
+func (stats *cdcStats) print(w io.Writer) {
+	ratio := 1.0
+	if stats.uniqueBytes > 0 {
+		ratio = float64(stats.bytes) / float64(stats.uniqueBytes)
+	}
+	fmt.Fprintf(w, "Total: %d bytes in %d chunks\n", stats.bytes, stats.chunks)
+	fmt.Fprintf(w, "Unique: %d bytes in %d chunks\n", stats.uniqueBytes, stats.uniqueChunks)
+	fmt.Fprintf(w, "Deduplication ratio: %.2f\n", ratio)
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"crypto"
+	"math/rand/v2"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func Test_parseCDC(t *testing.T) {
+	p, err := parseCDC("64K")
+	if err != nil || p.min != 16<<10 || p.avg != 64<<10 || p.max != 256<<10 {
+		t.Errorf("parseCDC() got %v, %v", p, err)
+	}
+	p, err = parseCDC("2K:8K:64K")
+	if err != nil || p.min != 2<<10 || p.avg != 8<<10 || p.max != 64<<10 {
+		t.Errorf("parseCDC() got %v, %v", p, err)
+	}
+	for _, str := range []string{"", "8K:2K:64K", "1:2", "16"} {
+		if _, err := parseCDC(str); err == nil {
+			t.Errorf("parseCDC(%q) should fail", str)
+		}
+	}
+}
+
+func Test_hashChunks(t *testing.T) {
+	data := make([]byte, 1<<20)
+	r := rand.NewChaCha8([32]byte{})
+	_, _ = r.Read(data)
+
+	dir := t.TempDir()
+	file1 := filepath.Join(dir, "file1")
+	file2 := filepath.Join(dir, "file2")
+	if err := os.WriteFile(file1, data, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	// Insert some bytes at the beginning so only the first chunks differ
+	if err := os.WriteFile(file2, append([]byte("shifted"), data...), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	oldChosen, oldCDC := chosen, opts.cdc
+	defer func() { chosen, opts.cdc = oldChosen, oldCDC }()
+	chosen = []crypto.Hash{crypto.SHA256}
+	var err error
+	if opts.cdc, err = parseCDC("16K"); err != nil {
+		t.Fatal(err)
+	}
+
+	var stats cdcStats
+	for _, file := range []string{file1, file2} {
+		results, err := hashChunks(file, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var total Size
+		for i, chunk := range results.pieces {
+			if chunk.size > opts.cdc.max || chunk.size < opts.cdc.min && i != len(results.pieces)-1 {
+				t.Errorf("hashChunks(%q) got chunk of %d bytes", file, chunk.size)
+			}
+			if chunk.offset != total {
+				t.Errorf("hashChunks(%q) got chunk at offset %d; want %d", file, chunk.offset, total)
+			}
+			total += chunk.size
+		}
+		if total != results.size {
+			t.Errorf("hashChunks(%q) got %d bytes; want %d", file, total, results.size)
+		}
+		stats.add(results)
+	}
+
+	if stats.bytes != 2<<20+7 {
+		t.Errorf("cdcStats got %d bytes; want %d", stats.bytes, 2<<20+7)
+	}
+	if stats.uniqueBytes > stats.bytes/2+4*opts.cdc.max {
+		t.Errorf("cdcStats got %d unique bytes of %d", stats.uniqueBytes, stats.bytes)
+	}
+}
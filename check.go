@@ -7,10 +7,20 @@ import (
 	"fmt"
 	"io"
 	"log"
+	"regexp"
 	"slices"
+	"strconv"
 	"strings"
 )
 
+// Format of the lines written by the --size option
+var sizeRegex = struct {
+	bsd, gnu *regexp.Regexp
+}{
+	regexp.MustCompile(`(?s)^SIZE \((.*)\) = ([0-9]+)$`),
+	regexp.MustCompile(`(?s)^\\?([0-9]+) [ \*](.*)$`),
+}
+
 // Choose the algorithm with the longest digest size
 func bestHash(checksums []*Checksum, ignore crypto.Hash) *Checksum {
 	minIndex := int(^uint(0) >> 1) // math.MaxInt32
@@ -57,11 +67,28 @@ func parseLine(line string, zeroTerminated bool) (*Checksums, error) {
 		file = unescapeFilename(file)
 	}
 
+	checksum, err := parseDigest(algorithm, digest)
+	if err != nil {
+		return nil, err
+	}
+	return &Checksums{
+		file:      file,
+		checksums: []*Checksum{checksum},
+	}, nil
+}
+
+// Decode digest, guessing its algorithm if not specified
+func parseDigest(algorithm, digest string) (*Checksum, error) {
 	var sum []byte
 	var err error
-	/* All hashes except those with 384-bits have Base64 padding */
-	if strings.HasSuffix(digest, "=") {
+	/* Multihashes are self-describing */
+	if h, mh, e := parseMultihash(digest); e == nil && algorithms[h] != nil {
+		sum, algorithm = mh, algorithms[h].name
+	} else if strings.HasSuffix(digest, "=") {
+		/* All hashes except those with 384-bits have Base64 padding */
 		sum, err = base64.StdEncoding.DecodeString(digest)
+	} else if len(digest) == tthBase32Len {
+		sum, err = tthEncoding.DecodeString(digest)
 	} else {
 		sum, err = hex.DecodeString(digest)
 	}
@@ -82,14 +109,9 @@ func parseLine(line string, zeroTerminated bool) (*Checksums, error) {
 	if hash, ok := name2Hash[algorithm]; !ok || len(chosen) > 0 && !slices.Contains(chosen, hash) {
 		return nil, fmt.Errorf("invalid digest")
 	} else {
-		return &Checksums{
-			file: file,
-			checksums: []*Checksum{
-				{
-					hash: hash,
-					csum: sum,
-				},
-			},
+		return &Checksum{
+			hash: hash,
+			csum: sum,
 		}, nil
 	}
 }
@@ -149,3 +171,73 @@ func inputFromCheck(f io.ReadCloser, zeroTerminated bool, onError ErrorAction) <
 
 	return files
 }
+
+// Parse a line written by the --size option
+func parseSizeLine(line string, zeroTerminated bool) (file string, size Size, ok bool) {
+	var digits string
+	if match := sizeRegex.bsd.FindStringSubmatch(line); match != nil {
+		file, digits = match[1], match[2]
+	} else if match = sizeRegex.gnu.FindStringSubmatch(line); match != nil {
+		digits, file = match[1], match[2]
+	} else {
+		return "", 0, false
+	}
+	size, err := strconv.ParseInt(digits, 10, 64)
+	if err != nil {
+		return "", 0, false
+	}
+	if !zeroTerminated {
+		file = unescapeFilename(file)
+	}
+	return file, size, true
+}
+
+// Read all the checksums of a manifest keeping every algorithm, unlike -c, and the sizes written by --size.
+// Lines for the same file are merged even if not consecutive.  The size is -1 if not listed.
+func readChecksums(f io.ReadCloser, zeroTerminated bool, onError ErrorAction) ([]*Checksums, error) {
+	defer f.Close()
+
+	scanner, err := getScanner(f, zeroTerminated)
+	if err != nil {
+		return nil, err
+	}
+
+	var entries []*Checksums
+	index := make(map[string]*Checksums)
+	entry := func(file string) *Checksums {
+		if index[file] == nil {
+			index[file] = &Checksums{file: file, size: -1}
+			entries = append(entries, index[file])
+		}
+		return index[file]
+	}
+	var lineno uint64
+	for scanner.Scan() {
+		lineno++
+		input, err := parseLine(scanner.Text(), zeroTerminated)
+		if err != nil || len(input.checksums[0].csum) == 0 {
+			if file, size, ok := parseSizeLine(scanner.Text(), zeroTerminated); ok {
+				entry(file).size = size
+				continue
+			}
+			if err == nil {
+				err = fmt.Errorf("invalid digest")
+			}
+			switch onError {
+			case ErrorWarn:
+				log.Printf("%v at line %d", err, lineno)
+			case ErrorExit:
+				log.Fatalf("%v at line %d", err, lineno)
+			}
+			continue
+		}
+		e := entry(input.file)
+		checksum := input.checksums[0]
+		if i := slices.IndexFunc(e.checksums, func(c *Checksum) bool { return c.hash == checksum.hash }); i >= 0 {
+			e.checksums[i] = checksum
+		} else {
+			e.checksums = append(e.checksums, checksum)
+		}
+	}
+	return entries, scanner.Err()
+}
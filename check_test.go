@@ -89,6 +89,15 @@ func Test_parseLine(t *testing.T) {
 				},
 			},
 		},
+		"zQmaozNR7DZHQK1ZcU9p7QdrshMvXqWK6gpu5rmrkPdT3L4  /etc/passwd": {
+			file: "/etc/passwd",
+			checksums: []*Checksum{
+				{
+					hash: crypto.SHA256,
+					csum: []byte{0xb9, 0x4d, 0x27, 0xb9, 0x93, 0x4d, 0x3e, 0x08, 0xa5, 0x2e, 0x52, 0xd7, 0xda, 0x7d, 0xab, 0xfa, 0xc4, 0x84, 0xef, 0xe3, 0x7a, 0x53, 0x80, 0xee, 0x90, 0x88, 0xf7, 0xac, 0xe2, 0xef, 0xcd, 0xe9},
+				},
+			},
+		},
 	}
 	oldChosen := chosen
 	defer func() { chosen = oldChosen }()
@@ -209,3 +218,31 @@ func Test_inputFromCheck(t *testing.T) {
 		}
 	}
 }
+
+func Test_readChecksums(t *testing.T) {
+	oldChosen := chosen
+	defer func() { chosen = oldChosen }()
+	chosen = nil
+
+	// Lines of sha256sum & sha512sum concatenated
+	manifest := strings.Join([]string{
+		"ca978112ca1bbdcafac231b39a23dc4da786eff8147c4e72b9807785afee48bb  a",
+		"3e23e8160039594a33894f6564e1b1348bbd7a0088d42c4acb73eeaed59c009d  b",
+		"1f40fc92da241694750979ee6cf582f2d5d7d28e18335de05abc54d0560e0f5302860c652bf08d560252aa5e74210546f369fbbbce8c12cfc7957b2652fe9a75  a",
+		"SIZE (b) = 1",
+		"garbage",
+	}, "\n")
+	got, err := readChecksums(io.NopCloser(strings.NewReader(manifest)), false, ErrorIgnore)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 2 || got[0].file != "a" || got[1].file != "b" {
+		t.Fatalf("readChecksums() got %v", got)
+	}
+	if len(got[0].checksums) != 2 || got[0].checksums[0].hash != crypto.SHA256 || got[0].checksums[1].hash != crypto.SHA512 || got[0].size != -1 {
+		t.Errorf("readChecksums() got %v with size %d for a", got[0].checksums, got[0].size)
+	}
+	if len(got[1].checksums) != 1 || got[1].size != 1 {
+		t.Errorf("readChecksums() got %v with size %d for b", got[1].checksums, got[1].size)
+	}
+}
@@ -0,0 +1,179 @@
+package main
+
+import (
+	"bufio"
+	"crypto"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"hash"
+	"io"
+	"log"
+	"regexp"
+	"strings"
+)
+
+// Dropbox hashes 4 MiB blocks with SHA-256 and then hashes the concatenation of their digests
+// https://www.dropbox.com/developers/reference/content-hash
+const dropboxBlockSize = 4 << 20
+
+type dropboxHash struct {
+	block  hash.Hash
+	n      int
+	blocks []byte
+}
+
+func newDropbox() hash.Hash {
+	return &dropboxHash{block: sha256.New()}
+}
+
+func (d *dropboxHash) Write(p []byte) (int, error) {
+	written := len(p)
+	for len(p) > 0 {
+		n := min(len(p), dropboxBlockSize-d.n)
+		d.block.Write(p[:n])
+		d.n += n
+		p = p[n:]
+		if d.n == dropboxBlockSize {
+			d.blocks = d.block.Sum(d.blocks)
+			d.block.Reset()
+			d.n = 0
+		}
+	}
+	return written, nil
+}
+
+func (d *dropboxHash) Sum(b []byte) []byte {
+	h := sha256.New()
+	h.Write(d.blocks)
+	if d.n > 0 {
+		h.Write(d.block.Sum(nil))
+	}
+	return h.Sum(b)
+}
+
+func (d *dropboxHash) Reset() {
+	d.block.Reset()
+	d.n = 0
+	d.blocks = nil
+}
+
+func (d *dropboxHash) Size() int      { return sha256.Size }
+func (d *dropboxHash) BlockSize() int { return sha256.BlockSize }
+
+// Hashes & encodings used by cloud providers in their object listings
+var clouds = map[string]struct {
+	hashes []crypto.Hash
+	base64 bool
+}{
+	// Content-MD5 header of Azure Blob Storage
+	"azure": {[]crypto.Hash{crypto.MD5}, true},
+	// content_hash of Dropbox
+	"dropbox": {[]crypto.Hash{DROPBOX}, false},
+	// As shown by gsutil hash
+	"gcs": {[]crypto.Hash{CRC32C, crypto.MD5}, true},
+}
+
+var cloudRegex = struct {
+	gsutil, hash, bsd, gnu *regexp.Regexp
+}{
+	regexp.MustCompile(`^Hashes \[(base64|hex)\] for (.*):$`),
+	regexp.MustCompile(`^\s+Hash \(([a-z0-9]+)\):\s+(\S+)$`),
+	regexp.MustCompile(`(?s)^([A-Za-z]+[A-Za-z0-9-]*) ?\((.*?)\) ?= "?([0-9a-zA-Z/+_-]+={0,2})"?$`),
+	regexp.MustCompile(`(?s)^"?([0-9a-zA-Z/+_-]+={0,2})"?\s+[ \*]?(.*)$`),
+}
+
+// Decode a digest in hexadecimal or Base64 using its expected size
+func decodeCloudSum(digest string, h crypto.Hash) []byte {
+	checksum := &Checksum{hash: h}
+	initHash(checksum)
+	size := checksum.Size()
+	if sum, err := hex.DecodeString(digest); err == nil && len(sum) == size {
+		return sum
+	}
+	digest = strings.NewReplacer("-", "+", "_", "/").Replace(strings.TrimRight(digest, "="))
+	if sum, err := base64.RawStdEncoding.DecodeString(digest); err == nil && len(sum) == size {
+		return sum
+	}
+	return nil
+}
+
+// Guess the algorithm of a digest in a listing of the given cloud provider
+func cloudHash(cloud string, name string, digest string) (crypto.Hash, []byte) {
+	for _, h := range clouds[cloud].hashes {
+		if name != "" && !strings.EqualFold(name, algorithms[h].name) && !strings.EqualFold(name, "content-"+algorithms[h].name) {
+			continue
+		}
+		if sum := decodeCloudSum(digest, h); sum != nil {
+			return h, sum
+		}
+	}
+	return 0, nil
+}
+
+// Used by the -c option with --cloud to read object listings
+func inputFromCloud(f io.ReadCloser, cloud string, zeroTerminated bool, onError ErrorAction) <-chan *Checksums {
+	files := make(chan *Checksums, chanSize)
+
+	go func() {
+		defer close(files)
+		defer f.Close()
+
+		scanner := bufio.NewScanner(f)
+		if zeroTerminated {
+			scanner.Split(scanLinesZ)
+		}
+
+		var input *Checksums
+		flush := func(file string) {
+			if input != nil && input.file != file {
+				files <- input
+				input = nil
+			}
+			if input == nil {
+				input = &Checksums{file: file}
+			}
+		}
+
+		var lineno uint64
+		var gsutilFile string
+		for scanner.Scan() {
+			lineno++
+			line := scanner.Text()
+			var name, file, digest string
+			if match := cloudRegex.gsutil.FindStringSubmatch(line); match != nil {
+				gsutilFile = match[2]
+				continue
+			} else if match = cloudRegex.hash.FindStringSubmatch(line); match != nil && gsutilFile != "" {
+				name, file, digest = match[1], gsutilFile, match[2]
+			} else if match = cloudRegex.bsd.FindStringSubmatch(line); match != nil {
+				name, file, digest = match[1], match[2], match[3]
+			} else if match = cloudRegex.gnu.FindStringSubmatch(line); match != nil {
+				digest, file = match[1], match[2]
+			}
+			if !zeroTerminated {
+				file = unescapeFilename(file)
+			}
+			h, sum := cloudHash(cloud, name, digest)
+			if sum == nil || file == "" {
+				switch onError {
+				case ErrorWarn:
+					log.Printf("invalid digest at line %d", lineno)
+				case ErrorExit:
+					log.Fatalf("invalid digest at line %d", lineno)
+				}
+				continue
+			}
+			flush(file)
+			input.checksums = append(input.checksums, &Checksum{hash: h, csum: sum})
+		}
+		if err := scanner.Err(); err != nil {
+			log.Fatal(err)
+		}
+		if input != nil {
+			files <- input
+		}
+	}()
+
+	return files
+}
@@ -0,0 +1,47 @@
+package main
+
+import (
+	"crypto"
+	"encoding/hex"
+	"io"
+	"strings"
+	"testing"
+)
+
+func Test_dropboxHash(t *testing.T) {
+	// SHA256 of the concatenation of the SHA256 of each 4 MiB block
+	data := strings.Repeat("a", dropboxBlockSize+1)
+	xwant := map[string]string{
+		"":   "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855",
+		"a":  "bf5d3affb73efd2ec6c36ad3112dd933efed63c4e1cbffcfa88e2759c144f2d8",
+		data: "5f858b62ccd88447586305aec6fd53c96747cfebf527cbba129a6dfed47d9624",
+	}
+	for str, want := range xwant {
+		h := newDropbox()
+		h.Write([]byte(str))
+		if got := hex.EncodeToString(h.Sum(nil)); got != want {
+			t.Errorf("dropbox(%q) got %s; want %s", str, got, want)
+		}
+	}
+}
+
+func Test_inputFromCloud(t *testing.T) {
+	listing := `Hashes [base64] for n9:
+	Hash (crc32c):		4waSgw==
+	Hash (md5):		JfnnlDI7RTiF9RgfG2JNCw==
+"25f9e794323b453885f5181f1b624d0b"  other
+`
+	var got []*Checksums
+	for input := range inputFromCloud(io.NopCloser(strings.NewReader(listing)), "gcs", false, ErrorIgnore) {
+		got = append(got, input)
+	}
+	if len(got) != 2 || got[0].file != "n9" || len(got[0].checksums) != 2 || got[1].file != "other" {
+		t.Fatalf("inputFromCloud() got %v", got)
+	}
+	if got[0].checksums[0].hash != CRC32C || hex.EncodeToString(got[0].checksums[0].csum) != "e3069283" {
+		t.Errorf("inputFromCloud() got %v", got[0].checksums[0])
+	}
+	if got[1].checksums[0].hash != crypto.MD5 {
+		t.Errorf("inputFromCloud() got %v", got[1].checksums[0])
+	}
+}
@@ -0,0 +1,191 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"strings"
+
+	"golang.org/x/sync/errgroup"
+)
+
+// Status of a file when comparing two trees with --compare
+type compareStatus int
+
+const (
+	compareSame compareStatus = iota
+	compareDiffers
+	compareOnlyLeft
+	compareOnlyRight
+	compareError
+)
+
+// Result for a file relative to both roots
+type compareResult struct {
+	path   string
+	status compareStatus
+	size   bool // Differs in size
+}
+
+// Files under root by their path relative to root, and the relative paths that couldn't be walked
+func walkTree(root string) (files map[string]string, failed []string) {
+	isSymlink := func(d fs.DirEntry) bool { return d.Type()&fs.ModeType == fs.ModeSymlink }
+	files = make(map[string]string)
+	_ = filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		rel, relErr := filepath.Rel(root, path)
+		if relErr != nil {
+			log.Print(relErr)
+			return nil
+		}
+		if err != nil {
+			log.Print(err)
+			failed = append(failed, rel)
+		} else if opts.followSymlinks && isSymlink(d) || !d.IsDir() && !isSymlink(d) {
+			files[rel] = path
+		}
+		return nil
+	})
+	return files, failed
+}
+
+// Compare the files under two roots, by size & then by the chosen hashes
+func compareTrees(left, right string) ([]*compareResult, error) {
+	for _, root := range []string{left, right} {
+		if info, err := os.Stat(root); err != nil {
+			return nil, err
+		} else if !info.IsDir() {
+			return nil, fmt.Errorf("%s is not a directory", root)
+		}
+	}
+	leftFiles, leftFailed := walkTree(left)
+	rightFiles, rightFailed := walkTree(right)
+	return compareWalked(leftFiles, rightFiles, append(leftFailed, rightFailed...)), nil
+}
+
+// Compare the files found under both roots.  Paths that couldn't be walked are errors,
+// as are the files under them found in the other root.
+func compareWalked(leftFiles, rightFiles map[string]string, failed []string) []*compareResult {
+	underFailed := func(path string) bool {
+		return slices.ContainsFunc(failed, func(dir string) bool {
+			return dir == "." || path == dir || strings.HasPrefix(path, dir+string(filepath.Separator))
+		})
+	}
+
+	var paths []string
+	for path := range leftFiles {
+		paths = append(paths, path)
+	}
+	for path := range rightFiles {
+		if _, ok := leftFiles[path]; !ok {
+			paths = append(paths, path)
+		}
+	}
+	for _, path := range failed {
+		if _, ok := leftFiles[path]; !ok && !slices.Contains(paths, path) {
+			paths = append(paths, path)
+		}
+	}
+	slices.Sort(paths)
+
+	results := make([]*compareResult, len(paths))
+	g := new(errgroup.Group)
+	g.SetLimit(runtime.NumCPU())
+	for i, path := range paths {
+		results[i] = &compareResult{path: path}
+		leftFile, inLeft := leftFiles[path]
+		rightFile, inRight := rightFiles[path]
+		switch {
+		case inLeft != inRight && underFailed(path), !inLeft && !inRight:
+			results[i].status = compareError
+			continue
+		case !inRight:
+			results[i].status = compareOnlyLeft
+			continue
+		case !inLeft:
+			results[i].status = compareOnlyRight
+			continue
+		}
+		g.Go(func() error {
+			results[i].status, results[i].size = compareFiles(leftFile, rightFile)
+			return nil
+		})
+	}
+	_ = g.Wait()
+	return results
+}
+
+// Compare two files, hashing them only if they have the same size
+func compareFiles(left, right string) (status compareStatus, size bool) {
+	var sizes [2]Size
+	for i, file := range []string{left, right} {
+		info, err := os.Stat(file)
+		if err != nil {
+			log.Print(err)
+			return compareError, false
+		}
+		sizes[i] = info.Size()
+	}
+	if sizes[0] != sizes[1] {
+		return compareDiffers, true
+	}
+
+	var sums [2]*Checksums
+	for i, file := range []string{left, right} {
+		results, err := hashFile(file, nil)
+		if err != nil {
+			log.Print(err)
+			return compareError, false
+		}
+		sums[i] = results
+	}
+	for i := range sums[0].checksums {
+		if !bytes.Equal(sums[0].checksums[i].sum, sums[1].checksums[i].sum) {
+			return compareDiffers, false
+		}
+	}
+	return compareSame, false
+}
+
+// Print the results of --compare like diff -rq and return its exit status
+func printCompareResults(w io.Writer, results []*compareResult, left, right string) int {
+	var counts [compareError + 1]int
+	for _, result := range results {
+		counts[result.status]++
+		if opts.status {
+			continue
+		}
+		file := escapeFilename(result.path)
+		switch result.status {
+		case compareSame:
+			if !opts.quiet {
+				fmt.Fprintf(w, "%s: OK\n", file)
+			}
+		case compareDiffers:
+			if result.size {
+				fmt.Fprintf(w, "%s: DIFFERS in size\n", file)
+			} else {
+				fmt.Fprintf(w, "%s: DIFFERS\n", file)
+			}
+		case compareOnlyLeft:
+			fmt.Fprintf(w, "%s: only in %s\n", file, escapeFilename(left))
+		case compareOnlyRight:
+			fmt.Fprintf(w, "%s: only in %s\n", file, escapeFilename(right))
+		}
+	}
+	if !opts.status {
+		fmt.Fprintf(os.Stderr, "Identical: %d, Different: %d, Only in %s: %d, Only in %s: %d\n",
+			counts[compareSame], counts[compareDiffers], escapeFilename(left), counts[compareOnlyLeft], escapeFilename(right), counts[compareOnlyRight])
+	}
+	if counts[compareError] > 0 {
+		return 2
+	} else if counts[compareDiffers]+counts[compareOnlyLeft]+counts[compareOnlyRight] > 0 {
+		return 1
+	}
+	return 0
+}
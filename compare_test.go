@@ -0,0 +1,106 @@
+package main
+
+import (
+	"crypto"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func Test_compareTrees(t *testing.T) {
+	oldChosen := chosen
+	defer func() { chosen = oldChosen }()
+	chosen = []crypto.Hash{crypto.SHA256}
+
+	dir := t.TempDir()
+	left, right := filepath.Join(dir, "left"), filepath.Join(dir, "right")
+	files := map[string]string{
+		"left/same":      "abc",
+		"right/same":     "abc",
+		"left/sub/diff":  "abc",
+		"right/sub/diff": "abd",
+		"left/size":      "abc",
+		"right/size":     "abcd",
+		"left/old":       "abc",
+		"right/sub/new":  "abc",
+	}
+	for name, data := range files {
+		file := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(file), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(file, []byte(data), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	results, err := compareTrees(left, right)
+	if err != nil {
+		t.Fatal(err)
+	}
+	xwant := []compareResult{
+		{path: "old", status: compareOnlyLeft},
+		{path: "same", status: compareSame},
+		{path: "size", status: compareDiffers, size: true},
+		{path: filepath.Join("sub", "diff"), status: compareDiffers},
+		{path: filepath.Join("sub", "new"), status: compareOnlyRight},
+	}
+	if len(results) != len(xwant) {
+		t.Fatalf("compareTrees() got %d results; want %d", len(results), len(xwant))
+	}
+	for i, want := range xwant {
+		if *results[i] != want {
+			t.Errorf("compareTrees() got %+v; want %+v", *results[i], want)
+		}
+	}
+
+	oldStatus := opts.status
+	defer func() { opts.status = oldStatus }()
+	opts.status = true
+	if got := printCompareResults(io.Discard, results, left, right); got != 1 {
+		t.Errorf("printCompareResults() got %d; want 1", got)
+	}
+	if got := printCompareResults(io.Discard, results[1:2], left, right); got != 0 {
+		t.Errorf("printCompareResults() got %d; want 0", got)
+	}
+
+	if _, err := compareTrees(left, filepath.Join(left, "same")); err == nil {
+		t.Errorf("compareTrees() should fail with a file")
+	}
+}
+
+func Test_compareWalked(t *testing.T) {
+	// The right root had an unreadable "sub" directory
+	leftFiles := map[string]string{
+		"old":                      "left/old",
+		filepath.Join("sub", "x"):  "left/sub/x",
+		filepath.Join("subx", "y"): "left/subx/y",
+	}
+	results := compareWalked(leftFiles, map[string]string{}, []string{"sub"})
+	xwant := []compareResult{
+		{path: "old", status: compareOnlyLeft},
+		{path: "sub", status: compareError},
+		{path: filepath.Join("sub", "x"), status: compareError},
+		{path: filepath.Join("subx", "y"), status: compareOnlyLeft},
+	}
+	if len(results) != len(xwant) {
+		t.Fatalf("compareWalked() got %d results; want %d", len(results), len(xwant))
+	}
+	for i, want := range xwant {
+		if *results[i] != want {
+			t.Errorf("compareWalked() got %+v; want %+v", *results[i], want)
+		}
+	}
+
+	oldStatus := opts.status
+	defer func() { opts.status = oldStatus }()
+	opts.status = true
+	if got := printCompareResults(io.Discard, results, "left", "right"); got != 2 {
+		t.Errorf("printCompareResults() got %d; want 2", got)
+	}
+
+	if _, failed := walkTree(filepath.Join(t.TempDir(), "missing")); len(failed) != 1 || failed[0] != "." {
+		t.Errorf("walkTree() got %v failed", failed)
+	}
+}
@@ -0,0 +1,163 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+)
+
+// Destination of file given as or found under arg, relative to the parent of arg like cp -r
+func copyDest(arg, file, dir string) (string, error) {
+	rel, err := filepath.Rel(filepath.Dir(filepath.Clean(arg)), file)
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, rel), nil
+}
+
+// Copy file to dest while hashing it, then hash dest again to verify the copy
+func copyFile(file, dest string) (*Checksums, error) {
+	src, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	defer src.Close()
+
+	info, err := src.Stat()
+	if err != nil {
+		return nil, err
+	}
+	if !info.Mode().IsRegular() {
+		return nil, fmt.Errorf("%s is not a regular file", file)
+	}
+	if destInfo, err := os.Stat(dest); err == nil && os.SameFile(info, destInfo) {
+		return nil, fmt.Errorf("%s and %s are the same file", file, dest)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(dest), 0o755); err != nil {
+		return nil, err
+	}
+	dst, err := os.OpenFile(dest, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, info.Mode().Perm())
+	if err != nil {
+		return nil, err
+	}
+	checksums, size := hashF(io.NopCloser(io.TeeReader(src, dst)), nil)
+	if err := dst.Close(); err != nil {
+		return nil, err
+	}
+	if checksums == nil {
+		return nil, fmt.Errorf("%s: error copying to %s", file, dest)
+	}
+
+	if opts.preserve {
+		if err := os.Chmod(dest, info.Mode().Perm()); err != nil {
+			return nil, err
+		}
+		if err := os.Chtimes(dest, info.ModTime(), info.ModTime()); err != nil {
+			return nil, err
+		}
+	}
+
+	for _, checksum := range checksums {
+		checksum.csum = checksum.sum
+	}
+	return verifyCopy(dest, size, checksums)
+}
+
+// Hash dest again with the checksums of the source in csum
+func verifyCopy(dest string, size Size, checksums []*Checksum) (*Checksums, error) {
+	verify := make([]*Checksum, len(checksums))
+	for i, checksum := range checksums {
+		verify[i] = &Checksum{hash: checksum.hash, csum: checksum.csum}
+	}
+	results, err := hashFile(dest, verify)
+	if err != nil {
+		return nil, err
+	}
+	if results.size != size {
+		return nil, fmt.Errorf("%s: FAILED with size %d instead of %d", dest, results.size, size)
+	}
+	for _, checksum := range results.checksums {
+		if !bytes.Equal(checksum.sum, checksum.csum) {
+			return nil, fmt.Errorf("%s: %s FAILED", dest, algorithms[checksum.hash].name)
+		}
+	}
+	return results, nil
+}
+
+// Read a manifest written by --copy-to for the --resume option
+func readManifest(file string) map[string]*Checksums {
+	manifest := make(map[string]*Checksums)
+	f, err := os.Open(file)
+	if os.IsNotExist(err) {
+		return manifest
+	} else if err != nil {
+		log.Fatal(err)
+	}
+	entries, err := readChecksums(f, opts.zero, errorAction())
+	if err != nil {
+		log.Fatal(err)
+	}
+	for _, entry := range entries {
+		if len(entry.checksums) > 0 {
+			manifest[filepath.Clean(entry.file)] = entry
+		}
+	}
+	return manifest
+}
+
+// Copy files & directories given in args to dir, printing the checksums of the copies.
+// Files listed in manifest are skipped if both the source & its copy still verify.
+func copyFiles(args []string, dir string, manifest map[string]*Checksums) (failed int) {
+	copyOne := func(arg, file string) {
+		dest, err := copyDest(arg, file, dir)
+		if err != nil {
+			log.Print(err)
+			failed++
+			return
+		}
+		if entry, ok := manifest[dest]; ok {
+			// The source may have been modified after it was copied
+			if info, err := os.Stat(file); err == nil {
+				if _, err := verifyCopy(file, info.Size(), entry.checksums); err == nil {
+					if results, err := verifyCopy(dest, info.Size(), entry.checksums); err == nil {
+						printChecksums(results, opts)
+						return
+					}
+				}
+			}
+		}
+		results, err := copyFile(file, dest)
+		if err != nil {
+			log.Print(err)
+			failed++
+			return
+		}
+		printChecksums(results, opts)
+	}
+
+	for _, arg := range args {
+		if !opts.recursive {
+			copyOne(arg, arg)
+			continue
+		}
+		err := filepath.WalkDir(arg, func(path string, d fs.DirEntry, err error) error {
+			if err != nil {
+				log.Print(err)
+				failed++
+			} else if d.Type().IsRegular() || opts.followSymlinks && d.Type()&fs.ModeSymlink != 0 {
+				copyOne(arg, path)
+			}
+			return nil
+		})
+		if err != nil {
+			log.Print(err)
+			failed++
+		}
+	}
+	return failed
+}
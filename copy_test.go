@@ -0,0 +1,124 @@
+package main
+
+import (
+	"crypto"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"text/template"
+	"time"
+)
+
+func Test_copyDest(t *testing.T) {
+	tests := []struct {
+		arg, file, want string
+	}{
+		{"file", "file", "dst/file"},
+		{"a/b/file", "a/b/file", "dst/file"},
+		{"src", "src/sub/file", "dst/src/sub/file"},
+		{"src/", "src/file", "dst/src/file"},
+	}
+	for _, tt := range tests {
+		if got, err := copyDest(tt.arg, tt.file, "dst"); err != nil || got != tt.want {
+			t.Errorf("copyDest(%q, %q) got %q, %v; want %q", tt.arg, tt.file, got, err, tt.want)
+		}
+	}
+}
+
+func Test_copyFile(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "file")
+	if err := os.WriteFile(file, []byte("abc"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	mtime := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	if err := os.Chtimes(file, mtime, mtime); err != nil {
+		t.Fatal(err)
+	}
+
+	oldChosen, oldPreserve := chosen, opts.preserve
+	defer func() { chosen, opts.preserve = oldChosen, oldPreserve }()
+	chosen = []crypto.Hash{crypto.MD5}
+	opts.preserve = true
+
+	dest := filepath.Join(dir, "dst", "sub", "file")
+	got, err := copyFile(file, dest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.file != dest || got.size != 3 || string(got.checksums[0].sum) != string(got.checksums[0].csum) {
+		t.Errorf("copyFile() got %s %d %x %x", got.file, got.size, got.checksums[0].sum, got.checksums[0].csum)
+	}
+	info, err := os.Stat(dest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode().Perm() != 0o600 || !info.ModTime().Equal(mtime) {
+		t.Errorf("copyFile() got mode %v & mtime %v; want %v & %v", info.Mode().Perm(), info.ModTime(), os.FileMode(0o600), mtime)
+	}
+
+	// A corrupted copy fails to verify
+	if err := os.WriteFile(dest, []byte("abd"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := verifyCopy(dest, 3, got.checksums); err == nil {
+		t.Errorf("verifyCopy() should fail with a corrupted copy")
+	}
+
+	if _, err := copyFile(file, file); err == nil {
+		t.Errorf("copyFile() should refuse to copy a file onto itself")
+	}
+}
+
+func Test_copyFilesResume(t *testing.T) {
+	oldChosen, oldFormat, oldStdout := chosen, format, stdout
+	defer func() { chosen, format, stdout = oldChosen, oldFormat, oldStdout }()
+	chosen = []crypto.Hash{crypto.MD5, crypto.SHA1}
+	format = template.Must(template.New("format").Parse(bsdFormat))
+	stdout = io.Discard
+
+	dir := t.TempDir()
+	file := filepath.Join(dir, "file")
+	dest := filepath.Join(dir, "dst", "file")
+	if err := os.MkdirAll(filepath.Dir(dest), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{file, dest} {
+		if err := os.WriteFile(name, []byte("abc"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	mtime := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	if err := os.Chtimes(dest, mtime, mtime); err != nil {
+		t.Fatal(err)
+	}
+	// MD5 & SHA1 of "abc"
+	list := filepath.Join(dir, "MANIFEST")
+	if err := os.WriteFile(list, []byte("MD5 ("+dest+") = 900150983cd24fb0d6963f7d28e17f72\nSHA1 ("+dest+") = a9993e364706816aba3e25717850c26c9cd0d89d\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	manifest := readManifest(list)
+	if entry := manifest[dest]; entry == nil || len(entry.checksums) != 2 {
+		t.Fatalf("readManifest() got %v", manifest)
+	}
+
+	// An unchanged source is skipped
+	if failed := copyFiles([]string{file}, filepath.Join(dir, "dst"), manifest); failed != 0 {
+		t.Fatalf("copyFiles() failed %d", failed)
+	}
+	if info, err := os.Stat(dest); err != nil || !info.ModTime().Equal(mtime) {
+		t.Errorf("copyFiles() copied an unchanged file")
+	}
+
+	// A source modified after it was copied is copied again even with the same size
+	if err := os.WriteFile(file, []byte("abd"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if failed := copyFiles([]string{file}, filepath.Join(dir, "dst"), manifest); failed != 0 {
+		t.Fatalf("copyFiles() failed %d", failed)
+	}
+	if data, err := os.ReadFile(dest); err != nil || string(data) != "abd" {
+		t.Errorf("copyFiles() got %q; want %q", data, "abd")
+	}
+}
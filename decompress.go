@@ -0,0 +1,60 @@
+package main
+
+import (
+	"bytes"
+	"compress/bzip2"
+	"compress/gzip"
+	"io"
+
+	"github.com/klauspost/compress/zstd"
+	"github.com/ulikunitz/xz"
+)
+
+func gunzip(r io.Reader) (io.ReadCloser, error) {
+	return gzip.NewReader(r)
+}
+
+func bunzip2(r io.Reader) (io.ReadCloser, error) {
+	return io.NopCloser(bzip2.NewReader(r)), nil
+}
+
+func unxz(r io.Reader) (io.ReadCloser, error) {
+	xr, err := xz.NewReader(r)
+	return io.NopCloser(xr), err
+}
+
+func unzstd(r io.Reader) (io.ReadCloser, error) {
+	zr, err := zstd.NewReader(r)
+	if err != nil {
+		return nil, err
+	}
+	return zr.IOReadCloser(), nil
+}
+
+// Magic bytes of the supported compression formats
+var compressions = []struct {
+	magic      []byte
+	decompress func(io.Reader) (io.ReadCloser, error)
+}{
+	{[]byte{0x1f, 0x8b}, gunzip},
+	{[]byte("BZh"), bunzip2},
+	{[]byte{0xfd, '7', 'z', 'X', 'Z', 0x00}, unxz},
+	{[]byte{0x28, 0xb5, 0x2f, 0xfd}, unzstd},
+}
+
+// Used by the --decompress option to decompress streams in known formats & pass through the rest
+func decompress(f io.Reader) (io.ReadCloser, error) {
+	peek := make([]byte, 6)
+	n, err := io.ReadFull(f, peek)
+	if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+		return nil, err
+	}
+
+	r := io.MultiReader(bytes.NewReader(peek[:n]), f)
+	for _, compression := range compressions {
+		if bytes.HasPrefix(peek[:n], compression.magic) {
+			return compression.decompress(r)
+		}
+	}
+	return io.NopCloser(r), nil
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto"
+	"encoding/hex"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/klauspost/compress/zstd"
+	"github.com/ulikunitz/xz"
+)
+
+func Test_decompress(t *testing.T) {
+	data := strings.Repeat("log line\n", 1000)
+
+	compressed := map[string]func(io.Writer) (io.WriteCloser, error){
+		"gzip": func(w io.Writer) (io.WriteCloser, error) { return gzip.NewWriter(w), nil },
+		"xz":   func(w io.Writer) (io.WriteCloser, error) { return xz.NewWriter(w) },
+		"zstd": func(w io.Writer) (io.WriteCloser, error) { return zstd.NewWriter(w) },
+	}
+	inputs := map[string][]byte{
+		"plain": []byte(data),
+		"short": []byte("BZ"),
+	}
+	for name, newWriter := range compressed {
+		var buf bytes.Buffer
+		w, err := newWriter(&buf)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := io.WriteString(w, data); err != nil {
+			t.Fatal(err)
+		}
+		if err := w.Close(); err != nil {
+			t.Fatal(err)
+		}
+		inputs[name] = buf.Bytes()
+	}
+
+	for name, input := range inputs {
+		r, err := decompress(bytes.NewReader(input))
+		if err != nil {
+			t.Fatalf("decompress(%s) got %v", name, err)
+		}
+		got, err := io.ReadAll(r)
+		if err != nil {
+			t.Fatalf("decompress(%s) got %v", name, err)
+		}
+		r.Close()
+		want := data
+		if name == "short" {
+			want = string(input)
+		}
+		if string(got) != want {
+			t.Errorf("decompress(%s) got %d bytes; want %d", name, len(got), len(want))
+		}
+	}
+
+	if _, err := decompress(bytes.NewReader([]byte{0x1f, 0x8b, 0})); err == nil {
+		t.Error("decompress() on a truncated gzip header should fail")
+	}
+}
+
+func Test_hashFileDecompress(t *testing.T) {
+	var buf bytes.Buffer
+	w := gzip.NewWriter(&buf)
+	w.Write([]byte("abc"))
+	w.Close()
+	file := filepath.Join(t.TempDir(), "file.gz")
+	if err := os.WriteFile(file, buf.Bytes(), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	oldChosen, oldOpts := chosen, opts
+	defer func() { chosen, opts = oldChosen, oldOpts }()
+	chosen = []crypto.Hash{crypto.SHA256}
+	opts.decompress = true
+
+	got, err := hashFile(file, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.size != 3 {
+		t.Errorf("hashFile() got size %d; want 3", got.size)
+	}
+	want := "ba7816bf8f01cfea414140de5dae2223b00361a396177a9cb410ff61f20015ad"
+	if sum := hex.EncodeToString(got.checksums[0].sum); sum != want {
+		t.Errorf("hashFile() got %s; want %s", sum, want)
+	}
+}
@@ -0,0 +1,199 @@
+package main
+
+import (
+	"cmp"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"runtime"
+	"slices"
+	"strings"
+	"sync"
+
+	"golang.org/x/sync/errgroup"
+)
+
+// Size of the blocks at the start & end of files hashed to filter candidates before full hashing
+const duplicatesBlockSize = 4096
+
+// Group of files with identical contents
+type duplicateGroup struct {
+	size  Size
+	files []*Checksums
+}
+
+// Hash of the first & last blocks of a file with the first chosen algorithm
+func hashEnds(file string, size Size) ([]byte, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	h := &Checksum{hash: chosen[0]}
+	initHash(h)
+	if _, err := io.Copy(h, io.NewSectionReader(f, 0, min(size, duplicatesBlockSize))); err != nil {
+		return nil, err
+	}
+	if size > duplicatesBlockSize {
+		start := max(size-duplicatesBlockSize, duplicatesBlockSize)
+		if _, err := io.Copy(h, io.NewSectionReader(f, start, size-start)); err != nil {
+			return nil, err
+		}
+	}
+	return h.Sum(nil), nil
+}
+
+// Split each group of files by key, dropping files without duplicates
+func splitGroups(groups [][]*Checksums, key func(*Checksums) (string, error)) (result [][]*Checksums, failed uint64) {
+	var mutex sync.Mutex
+	g := new(errgroup.Group)
+	g.SetLimit(runtime.NumCPU())
+	for _, group := range groups {
+		keys := make([]string, len(group))
+		ok := make([]bool, len(group))
+		for i, file := range group {
+			g.Go(func() error {
+				k, err := key(file)
+				if err != nil {
+					log.Print(err)
+					mutex.Lock()
+					failed++
+					mutex.Unlock()
+					return nil
+				}
+				keys[i], ok[i] = k, true
+				return nil
+			})
+		}
+		if err := g.Wait(); err != nil {
+			log.Fatal(err)
+		}
+		subgroups := make(map[string][]*Checksums)
+		var order []string
+		for i, file := range group {
+			if !ok[i] {
+				continue
+			}
+			if _, found := subgroups[keys[i]]; !found {
+				order = append(order, keys[i])
+			}
+			subgroups[keys[i]] = append(subgroups[keys[i]], file)
+		}
+		for _, k := range order {
+			if len(subgroups[k]) > 1 {
+				result = append(result, subgroups[k])
+			}
+		}
+	}
+	return result, failed
+}
+
+// Find groups of files with identical contents, filtering by size & the hash of their ends before full hashing.
+// Empty files and hard links to an already seen file are ignored.
+func findDuplicates(lines <-chan *Checksums) (groups []duplicateGroup, failed uint64) {
+	bySize := make(map[Size][]*Checksums)
+	seen := make(map[Size][]os.FileInfo)
+	for line := range lines {
+		info, err := os.Stat(line.file)
+		if err != nil {
+			log.Print(err)
+			failed++
+			continue
+		}
+		if !info.Mode().IsRegular() || info.Size() == 0 {
+			continue
+		}
+		size := info.Size()
+		if slices.ContainsFunc(seen[size], func(other os.FileInfo) bool { return os.SameFile(info, other) }) {
+			continue
+		}
+		seen[size] = append(seen[size], info)
+		bySize[size] = append(bySize[size], &Checksums{file: line.file, size: size})
+	}
+
+	var candidates [][]*Checksums
+	for _, group := range bySize {
+		if len(group) > 1 {
+			candidates = append(candidates, group)
+		}
+	}
+
+	candidates, n := splitGroups(candidates, func(file *Checksums) (string, error) {
+		sum, err := hashEnds(file.file, file.size)
+		// Files not larger than two blocks are already fully hashed
+		if err == nil && file.size <= 2*duplicatesBlockSize && len(chosen) == 1 {
+			file.checksums = []*Checksum{{hash: chosen[0], sum: sum}}
+		}
+		return string(sum), err
+	})
+	failed += n
+	candidates, n = splitGroups(candidates, func(file *Checksums) (string, error) {
+		if file.checksums == nil {
+			results, err := hashFile(file.file, nil)
+			if err != nil {
+				return "", err
+			}
+			file.checksums = results.checksums
+		}
+		var key strings.Builder
+		for _, checksum := range file.checksums {
+			key.Write(checksum.sum)
+		}
+		return key.String(), nil
+	})
+	failed += n
+
+	for _, group := range candidates {
+		slices.SortFunc(group, func(a, b *Checksums) int { return strings.Compare(a.file, b.file) })
+		groups = append(groups, duplicateGroup{size: group[0].size, files: group})
+	}
+	// Most wasted space first
+	slices.SortFunc(groups, func(a, b duplicateGroup) int {
+		return cmp.Or(cmp.Compare(b.wasted(), a.wasted()), strings.Compare(a.files[0].file, b.files[0].file))
+	})
+	return groups, failed
+}
+
+func (group duplicateGroup) wasted() Size {
+	return group.size * Size(len(group.files)-1)
+}
+
+// Quote for the shell
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+// Print groups of duplicates with their checksums, or a shell script keeping the first file of each group
+// and replacing the others with hard links or deleting them
+func printDuplicates(groups []duplicateGroup, script string) {
+	if script != "" {
+		fmt.Fprintln(stdout, "#!/bin/sh")
+	}
+	var wasted Size
+	var files int
+	for i, group := range groups {
+		wasted += group.wasted()
+		files += len(group.files) - 1
+		if script == "" && i > 0 {
+			fmt.Fprintln(stdout)
+		}
+		fmt.Fprintf(stdout, "# %d files of %d bytes waste %d bytes\n", len(group.files), group.size, group.wasted())
+		keep := group.files[0].file
+		for _, file := range group.files {
+			switch {
+			case script == "":
+				printChecksums(file, opts)
+			case file.file == keep:
+				// Escape newlines that would end the comment
+				fmt.Fprintf(stdout, "# keep %s\n", escapeFilename(keep))
+			case script == "hardlink":
+				fmt.Fprintf(stdout, "ln -f -- %s %s\n", shellQuote(keep), shellQuote(file.file))
+			case script == "delete":
+				fmt.Fprintf(stdout, "rm -f -- %s\n", shellQuote(file.file))
+			}
+		}
+	}
+	fmt.Fprintf(os.Stderr, "Duplicates: %d files in %d groups waste %d bytes\n", files, len(groups), wasted)
+}
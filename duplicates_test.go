@@ -0,0 +1,115 @@
+package main
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/sha256"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func Test_hashEnds(t *testing.T) {
+	oldChosen := chosen
+	defer func() { chosen = oldChosen }()
+	chosen = []crypto.Hash{crypto.SHA256}
+
+	dir := t.TempDir()
+	for _, size := range []int{1, duplicatesBlockSize, duplicatesBlockSize + 1, 2 * duplicatesBlockSize, 3 * duplicatesBlockSize} {
+		data := bytes.Repeat([]byte("0123456789"), size/10+1)[:size]
+		file := filepath.Join(dir, "file")
+		if err := os.WriteFile(file, data, 0o644); err != nil {
+			t.Fatal(err)
+		}
+		got, err := hashEnds(file, Size(size))
+		if err != nil {
+			t.Fatal(err)
+		}
+		// Files up to two blocks are fully hashed
+		want := sha256.Sum256(data)
+		if size > 2*duplicatesBlockSize {
+			want = sha256.Sum256(append(bytes.Clone(data[:duplicatesBlockSize]), data[size-duplicatesBlockSize:]...))
+		}
+		if !bytes.Equal(got, want[:]) {
+			t.Errorf("hashEnds(%d) got %x; want %x", size, got, want)
+		}
+	}
+}
+
+func Test_findDuplicates(t *testing.T) {
+	oldChosen := chosen
+	defer func() { chosen = oldChosen }()
+	chosen = []crypto.Hash{crypto.SHA256}
+
+	dir := t.TempDir()
+	big := bytes.Repeat([]byte("a"), 3*duplicatesBlockSize)
+	// Same size & ends as big but different in the middle
+	middle := bytes.Clone(big)
+	middle[len(middle)/2] = 'b'
+	files := map[string][]byte{
+		"big1":   big,
+		"big2":   big,
+		"middle": middle,
+		"small1": []byte("abc"),
+		"small2": []byte("abc"),
+		"other":  []byte("abd"),
+		"empty1": nil,
+		"empty2": nil,
+	}
+	for name, data := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), data, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.Link(filepath.Join(dir, "big1"), filepath.Join(dir, "link")); err != nil {
+		t.Fatal(err)
+	}
+
+	lines := make(chan *Checksums, len(files)+1)
+	for _, name := range []string{"big1", "big2", "empty1", "empty2", "link", "middle", "other", "small1", "small2"} {
+		lines <- &Checksums{file: filepath.Join(dir, name)}
+	}
+	close(lines)
+
+	groups, failed := findDuplicates(lines)
+	if failed != 0 {
+		t.Errorf("findDuplicates() failed for %d files", failed)
+	}
+	xwant := [][]string{{"big1", "big2"}, {"small1", "small2"}}
+	if len(groups) != len(xwant) {
+		t.Fatalf("findDuplicates() got %d groups; want %d", len(groups), len(xwant))
+	}
+	for i, want := range xwant {
+		if len(groups[i].files) != len(want) {
+			t.Fatalf("findDuplicates() got %d files in group %d; want %d", len(groups[i].files), i, len(want))
+		}
+		for j, name := range want {
+			if got := groups[i].files[j]; got.file != filepath.Join(dir, name) || got.checksums == nil {
+				t.Errorf("findDuplicates() got %s in group %d; want %s", got.file, i, name)
+			}
+		}
+	}
+	if wasted := groups[0].wasted(); wasted != 3*duplicatesBlockSize {
+		t.Errorf("wasted() got %d; want %d", wasted, 3*duplicatesBlockSize)
+	}
+}
+
+func Test_shellQuote(t *testing.T) {
+	if got, want := shellQuote("it's"), `'it'\''s'`; got != want {
+		t.Errorf("shellQuote() got %s; want %s", got, want)
+	}
+}
+
+func Test_printDuplicates(t *testing.T) {
+	oldStdout := stdout
+	defer func() { stdout = oldStdout }()
+	var output bytes.Buffer
+	stdout = &output
+
+	groups := []duplicateGroup{{size: 1, files: []*Checksums{{file: "a\ntouch PWNED #"}, {file: "b"}}}}
+	printDuplicates(groups, "delete")
+	want := "#!/bin/sh\n# 2 files of 1 bytes waste 1 bytes\n# keep a\\ntouch PWNED #\nrm -f -- 'b'\n"
+	if output.String() != want {
+		t.Errorf("printDuplicates() got %q; want %q", output.String(), want)
+	}
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"crypto"
+	"encoding/base32"
+	"encoding/hex"
+	"hash"
+	"net/url"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"golang.org/x/crypto/md4"
+)
+
+// eDonkey2000 hashes files in chunks of 9500 KiB with MD4
+const ed2kChunkSize = 9728000
+
+type ed2kHash struct {
+	chunk  hash.Hash
+	n      int
+	chunks []byte // Concatenated MD4s of the complete chunks
+}
+
+func newED2K() hash.Hash {
+	return &ed2kHash{chunk: md4.New()}
+}
+
+func (e *ed2kHash) Write(p []byte) (int, error) {
+	written := len(p)
+	for len(p) > 0 {
+		n := min(len(p), ed2kChunkSize-e.n)
+		e.chunk.Write(p[:n])
+		e.n += n
+		p = p[n:]
+		if e.n == ed2kChunkSize {
+			e.chunks = e.chunk.Sum(e.chunks)
+			e.chunk.Reset()
+			e.n = 0
+		}
+	}
+	return written, nil
+}
+
+// Files of one partial chunk use its MD4, otherwise the MD4 of the MD4s of all chunks.
+// Like eMule, files with a multiple of the chunk size have a final empty chunk
+func (e *ed2kHash) Sum(in []byte) []byte {
+	if len(e.chunks) == 0 {
+		return e.chunk.Sum(in)
+	}
+	h := md4.New()
+	h.Write(e.chunks)
+	h.Write(e.chunk.Sum(nil))
+	return h.Sum(in)
+}
+
+func (e *ed2kHash) Reset() {
+	e.chunk.Reset()
+	e.n = 0
+	e.chunks = nil
+}
+
+func (e *ed2kHash) Size() int      { return md4.Size }
+func (e *ed2kHash) BlockSize() int { return ed2kChunkSize }
+
+// TTH is encoded in Base32 without padding
+var tthEncoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+var tthBase32Len = tthEncoding.EncodedLen(tigerSize)
+
+// Exact topics of the hashes supported in magnet links
+var magnetTopics = map[crypto.Hash]func([]byte) string{
+	TTH:         func(sum []byte) string { return "urn:tree:tiger:" + tthEncoding.EncodeToString(sum) },
+	ED2K:        func(sum []byte) string { return "urn:ed2k:" + hex.EncodeToString(sum) },
+	crypto.SHA1: func(sum []byte) string { return "urn:sha1:" + base32.StdEncoding.EncodeToString(sum) },
+	crypto.MD5:  func(sum []byte) string { return "urn:md5:" + hex.EncodeToString(sum) },
+}
+
+// Used by the --magnet option
+func magnetLink(results *Checksums) string {
+	var params []string
+	for _, checksum := range results.checksums {
+		params = append(params, "xt="+magnetTopics[checksum.hash](checksum.sum))
+	}
+	params = append(params, "xl="+strconv.FormatInt(results.size, 10))
+	if results.file != "" {
+		name := url.QueryEscape(filepath.Base(results.file))
+		params = append(params, "dn="+strings.ReplaceAll(name, "+", "%20"))
+	}
+	return "magnet:?" + strings.Join(params, "&")
+}
@@ -0,0 +1,36 @@
+package main
+
+import (
+	"encoding/hex"
+	"testing"
+)
+
+func Test_ed2k(t *testing.T) {
+	xwant := map[int]string{
+		0:             "31d6cfe0d16ae931b73c59d7e0c089c0",
+		ed2kChunkSize: "fc21d9af828f92a8df64beac3357425d",
+	}
+	for size, want := range xwant {
+		h := newED2K()
+		h.Write(make([]byte, size))
+		if got := hex.EncodeToString(h.Sum(nil)); got != want {
+			t.Errorf("ed2k(%d) got %s; want %s", size, got, want)
+		}
+	}
+}
+
+func Test_magnetLink(t *testing.T) {
+	tth, _ := tthEncoding.DecodeString("LWPNACQDBZRYXW3VHJVCJ64QBZNGHOHHHZWCLNQ")
+	ed2k, _ := hex.DecodeString("31d6cfe0d16ae931b73c59d7e0c089c0")
+	results := &Checksums{
+		file: "dir/empty file&",
+		checksums: []*Checksum{
+			{hash: TTH, sum: tth},
+			{hash: ED2K, sum: ed2k},
+		},
+	}
+	want := "magnet:?xt=urn:tree:tiger:LWPNACQDBZRYXW3VHJVCJ64QBZNGHOHHHZWCLNQ&xt=urn:ed2k:31d6cfe0d16ae931b73c59d7e0c089c0&xl=0&dn=empty%20file%26"
+	if got := magnetLink(results); got != want {
+		t.Errorf("magnetLink() got %s; want %s", got, want)
+	}
+}
@@ -0,0 +1,203 @@
+package main
+
+import (
+	"bufio"
+	"crypto"
+	"crypto/md5"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// Default part size used by the AWS CLI
+const defaultPartSize = 8 << 20
+
+// Part sizes commonly used by S3 clients, tried when checking
+var commonPartSizes = []Size{
+	5 << 20,
+	8 << 20,
+	15 << 20,
+	16 << 20,
+	32 << 20,
+	50 << 20,
+	64 << 20,
+	100 << 20,
+	128 << 20,
+	256 << 20,
+	512 << 20,
+	1 << 30,
+}
+
+var etagRegex = struct {
+	bsd, plain *regexp.Regexp
+}{
+	// Format used by our own output with --etag
+	regexp.MustCompile(`(?s)^ETAG \((.*)\) = "?([0-9a-fA-F]{32})(?:-([0-9]+))?"?$`),
+	// ETag as listed by aws s3api list-objects, optionally quoted
+	regexp.MustCompile(`(?s)^"?([0-9a-fA-F]{32})(?:-([0-9]+))?"? [ \*]?(.*)$`),
+}
+
+// Compute the ETag of an upload: the MD5 of the object, or for multipart uploads
+// the MD5 of the concatenated MD5s of the parts followed by the number of parts
+func etag(f io.Reader, size Size, partSize Size, multipart bool) (string, error) {
+	if !multipart {
+		checksums, n := hashF(io.NopCloser(f), []*Checksum{{hash: crypto.MD5}})
+		if checksums == nil || n != size {
+			return "", fmt.Errorf("error computing ETag")
+		}
+		return hex.EncodeToString(checksums[0].sum), nil
+	}
+	h := md5.New()
+	parts := 0
+	for remaining := size; remaining > 0 || parts == 0; remaining -= partSize {
+		checksums, n := hashF(io.NopCloser(io.LimitReader(f, partSize)), []*Checksum{{hash: crypto.MD5}})
+		if checksums == nil || n != min(remaining, partSize) {
+			return "", fmt.Errorf("error computing ETag")
+		}
+		h.Write(checksums[0].sum)
+		parts++
+	}
+	return hex.EncodeToString(h.Sum(nil)) + "-" + strconv.Itoa(parts), nil
+}
+
+// Part sizes that result in the given number of parts
+func etagPartSizes(size Size, parts int) []Size {
+	if parts <= 1 {
+		return []Size{max(size, 1)}
+	}
+	var candidates []Size
+	if opts.partSize > 0 {
+		candidates = []Size{opts.partSize}
+	} else {
+		// Try the smallest part size, also rounded up to MiB, before the common ones
+		smallest := (size + Size(parts) - 1) / Size(parts)
+		candidates = append([]Size{smallest, (smallest + 1<<20 - 1) &^ (1<<20 - 1)}, commonPartSizes...)
+	}
+	var sizes []Size
+	for _, partSize := range candidates {
+		if (size+partSize-1)/partSize == Size(parts) && !slices.Contains(sizes, partSize) {
+			sizes = append(sizes, partSize)
+		}
+	}
+	return sizes
+}
+
+// Used by the --etag option
+func hashETag(file string, checksums []*Checksum) (*Checksums, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+	if info.IsDir() {
+		return nil, fmt.Errorf("%s is a directory", file)
+	}
+
+	// Compute the ETag with the given part size
+	if checksums == nil {
+		partSize := opts.partSize
+		if partSize == 0 {
+			partSize = defaultPartSize
+		}
+		sum, err := etag(f, info.Size(), partSize, info.Size() > partSize)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", file, err)
+		}
+		return &Checksums{
+			file:      file,
+			size:      info.Size(),
+			checksums: []*Checksum{{hash: crypto.MD5, sum: []byte(sum)}},
+		}, nil
+	}
+
+	// Try the part sizes that match the number of parts in the expected ETag
+	want := string(checksums[0].csum)
+	parts := 1
+	_, suffix, multipart := strings.Cut(want, "-")
+	if multipart {
+		parts, _ = strconv.Atoi(suffix)
+	}
+	sum := "no part size matches"
+	for _, partSize := range etagPartSizes(info.Size(), parts) {
+		if _, err := f.Seek(0, io.SeekStart); err != nil {
+			return nil, err
+		}
+		if sum, err = etag(f, info.Size(), partSize, multipart); err != nil {
+			return nil, fmt.Errorf("%s: %v", file, err)
+		}
+		if sum == want {
+			break
+		}
+	}
+	checksums[0].sum = []byte(sum)
+	return &Checksums{
+		file:      file,
+		size:      info.Size(),
+		checksums: checksums,
+	}, nil
+}
+
+// Used by the -c option with --etag
+func inputFromETag(f io.ReadCloser, zeroTerminated bool, onError ErrorAction) <-chan *Checksums {
+	files := make(chan *Checksums, chanSize)
+
+	go func() {
+		defer close(files)
+		defer f.Close()
+
+		scanner := bufio.NewScanner(f)
+		if zeroTerminated {
+			scanner.Split(scanLinesZ)
+		}
+		var lineno, valid uint64
+		for scanner.Scan() {
+			lineno++
+			var digest, parts, file string
+			if match := etagRegex.bsd.FindStringSubmatch(scanner.Text()); match != nil {
+				file, digest, parts = match[1], match[2], match[3]
+			} else if match = etagRegex.plain.FindStringSubmatch(scanner.Text()); match != nil {
+				digest, parts, file = match[1], match[2], match[3]
+			}
+			if file == "" {
+				switch onError {
+				case ErrorWarn:
+					log.Printf("invalid ETag at line %d", lineno)
+				case ErrorExit:
+					log.Fatalf("invalid ETag at line %d", lineno)
+				}
+				continue
+			}
+			valid++
+			sum := strings.ToLower(digest)
+			if parts != "" {
+				sum += "-" + parts
+			}
+			if !zeroTerminated {
+				file = unescapeFilename(file)
+			}
+			files <- &Checksums{
+				file:      file,
+				checksums: []*Checksum{{hash: crypto.MD5, csum: []byte(sum)}},
+			}
+		}
+		if err := scanner.Err(); err != nil {
+			log.Fatal(err)
+		}
+		if valid == 0 {
+			log.Fatal("No valid ETags found")
+		}
+	}()
+
+	return files
+}
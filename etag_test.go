@@ -0,0 +1,104 @@
+package main
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"text/template"
+)
+
+func Test_etag(t *testing.T) {
+	data := strings.Repeat("a", 12)
+	xwant := map[Size]string{
+		// MD5 of the concatenation of MD5("aaaaa"), MD5("aaaaa") & MD5("aa")
+		5:  "2615cdbe922634659654581b1895a338-3",
+		12: "4cc0e3687da5d31e0d6eae8f8687ffb9-1",
+	}
+	for partSize, want := range xwant {
+		got, err := etag(strings.NewReader(data), Size(len(data)), partSize, true)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != want {
+			t.Errorf("etag(%d) got %s; want %s", partSize, got, want)
+		}
+	}
+	got, err := etag(strings.NewReader("abc"), 3, defaultPartSize, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "900150983cd24fb0d6963f7d28e17f72"; got != want {
+		t.Errorf("etag() got %s; want %s", got, want)
+	}
+}
+
+func Test_etagPartSizes(t *testing.T) {
+	got := etagPartSizes(20000000, 3)
+	if !slices.Contains(got, 8<<20) || slices.Contains(got, 5<<20) {
+		t.Errorf("etagPartSizes() got %v", got)
+	}
+}
+
+func Test_hashETag(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "file")
+	if err := os.WriteFile(file, []byte(strings.Repeat("a", 12)), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	oldPartSize := opts.partSize
+	defer func() { opts.partSize = oldPartSize }()
+	opts.partSize = 5
+
+	reader := strings.NewReader("\"2615cdbe922634659654581b1895a338-3\"  " + file + "\n")
+	for input := range inputFromETag(io.NopCloser(reader), false, ErrorIgnore) {
+		got, err := hashETag(input.file, input.checksums)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(got.checksums[0].sum) != string(got.checksums[0].csum) {
+			t.Errorf("hashETag(%q) got %s; want %s", file, got.checksums[0].sum, got.checksums[0].csum)
+		}
+	}
+}
+
+func Test_etagRoundTrip(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "file (1)")
+	if err := os.WriteFile(file, []byte(strings.Repeat("a", 12)), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	oldPartSize, oldFormat := opts.partSize, format
+	defer func() { opts.partSize, format = oldPartSize, oldFormat }()
+	opts.partSize = 5
+	format = template.Must(template.New("format").Parse(bsdFormat))
+
+	results, err := hashETag(file, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var output strings.Builder
+	if err := format.Execute(&output, getOutput(results, Options{etag: true})); err != nil {
+		t.Fatal(err)
+	}
+	if want := "ETAG (" + file + ") = 2615cdbe922634659654581b1895a338-3\n"; output.String() != want {
+		t.Errorf("got %q; want %q", output.String(), want)
+	}
+
+	n := 0
+	for input := range inputFromETag(io.NopCloser(strings.NewReader(output.String())), false, ErrorExit) {
+		n++
+		got, err := hashETag(input.file, input.checksums)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(got.checksums[0].sum) != string(got.checksums[0].csum) {
+			t.Errorf("hashETag(%q) got %s; want %s", input.file, got.checksums[0].sum, got.checksums[0].csum)
+		}
+	}
+	if n != 1 {
+		t.Errorf("inputFromETag() got %d lines; want 1", n)
+	}
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"log"
+	"os"
+	"path/filepath"
+	"slices"
+)
+
+// Pass lines through while recording the files they reference, for the --check-extra option.
+// The checksum file itself counts as listed.
+func recordListed(lines <-chan *Checksums, manifest string) (<-chan *Checksums, map[string]bool) {
+	listed := make(map[string]bool)
+	if manifest != "" {
+		listed[normalizePath(manifest)] = true
+	}
+	files := make(chan *Checksums, chanSize)
+
+	go func() {
+		defer close(files)
+		for line := range lines {
+			listed[normalizePath(listedFile(line.file))] = true
+			files <- line
+		}
+	}()
+
+	return files, listed
+}
+
+// File referenced by a line, which may be a piece or an archive member
+func listedFile(file string) string {
+	if archive, _ := splitMember(file); archive != "" {
+		return archive
+	}
+	if match := pieceRegex.FindStringSubmatch(file); match != nil {
+		if _, err := os.Stat(file); err != nil {
+			return match[1]
+		}
+	}
+	return file
+}
+
+// Absolute & clean path so that manifest entries & walked files can be compared
+func normalizePath(file string) string {
+	if abs, err := filepath.Abs(file); err == nil {
+		return abs
+	}
+	return filepath.Clean(file)
+}
+
+// Files under dir not referenced by the manifest
+func findExtra(dir string, listed map[string]bool) (extra []string) {
+	if info, err := os.Stat(dir); err != nil {
+		log.Fatal(err)
+	} else if !info.IsDir() {
+		log.Fatalf("%s is not a directory", dir)
+	}
+	for line := range inputFromDir([]string{dir}, opts.followSymlinks) {
+		if !listed[normalizePath(line.file)] {
+			extra = append(extra, line.file)
+		}
+	}
+	slices.Sort(extra)
+	return extra
+}
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func Test_findExtra(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"a", "b.tar", "c", "CHECKSUMS", filepath.Join("sub", "d")} {
+		file := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(file), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(file, nil, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	lines := make(chan *Checksums, 3)
+	lines <- &Checksums{file: filepath.Join(dir, "a")}
+	lines <- &Checksums{file: filepath.Join(dir, "b.tar") + archiveSeparator + "member"}
+	// Relative paths are normalized
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	rel, err := filepath.Rel(wd, dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines <- &Checksums{file: rel + "/sub/../c offset 0-9"}
+	close(lines)
+
+	files, listed := recordListed(lines, filepath.Join(dir, "CHECKSUMS"))
+	n := 0
+	for range files {
+		n++
+	}
+	if n != 3 {
+		t.Errorf("recordListed() passed %d lines; want 3", n)
+	}
+
+	extra := findExtra(dir, listed)
+	if want := filepath.Join(dir, "sub", "d"); len(extra) != 1 || extra[0] != want {
+		t.Errorf("findExtra() got %v; want [%s]", extra, want)
+	}
+}
@@ -0,0 +1,124 @@
+package main
+
+import (
+	"bufio"
+	"crypto"
+	"fmt"
+	"hash"
+	"io"
+	"log"
+	"regexp"
+	"strings"
+
+	"github.com/glaslos/ssdeep"
+)
+
+// Fuzzy hashes are printable strings compared by similarity instead of equality
+var fuzzyHashes = []crypto.Hash{SSDEEP, TLSH}
+
+func init() {
+	// Hash small files like the ssdeep tool does
+	ssdeep.Force = true
+}
+
+func newSSDEEP() hash.Hash {
+	return ssdeep.New()
+}
+
+// Known fuzzy hash read with the --fuzzy-match option
+type fuzzyHash struct {
+	hash   crypto.Hash
+	digest string
+	file   string
+}
+
+var fuzzyRegex = struct {
+	ssdeep, tlsh, bsd *regexp.Regexp
+}{
+	// Format used by ssdeep -l & the GNU format
+	regexp.MustCompile(`(?s)^([0-9]+:[0-9A-Za-z+/]*:[0-9A-Za-z+/]*)(?:,"(.*)"|  (.*))$`),
+	// Format used by tlsh -r & the GNU format
+	regexp.MustCompile(`(?s)^((?:T1)?[0-9A-Fa-f]{70})(?:\t|  )(.*)$`),
+	regexp.MustCompile(`(?s)^(SSDEEP|TLSH) \((.*)\) = (\S+)$`),
+}
+
+// Read a manifest of known fuzzy hashes
+func inputFromFuzzy(f io.ReadCloser, zeroTerminated bool, onError ErrorAction) []*fuzzyHash {
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	if zeroTerminated {
+		scanner.Split(scanLinesZ)
+	}
+	var known []*fuzzyHash
+	var lineno uint64
+	for scanner.Scan() {
+		lineno++
+		line := scanner.Text()
+		var h crypto.Hash
+		var digest, file string
+		if match := fuzzyRegex.bsd.FindStringSubmatch(line); match != nil {
+			h, file, digest = name2Hash[match[1]], match[2], match[3]
+		} else if match = fuzzyRegex.ssdeep.FindStringSubmatch(line); match != nil {
+			h, digest, file = SSDEEP, match[1], match[2]+match[3]
+		} else if match = fuzzyRegex.tlsh.FindStringSubmatch(line); match != nil {
+			h, digest, file = TLSH, "T1"+strings.ToUpper(strings.TrimPrefix(match[1], "T1")), match[2]
+		} else if strings.HasPrefix(line, "ssdeep,") {
+			// Header of ssdeep -l
+			continue
+		}
+		if digest == "" {
+			switch onError {
+			case ErrorWarn:
+				log.Printf("invalid fuzzy hash at line %d", lineno)
+			case ErrorExit:
+				log.Fatalf("invalid fuzzy hash at line %d", lineno)
+			}
+			continue
+		}
+		if !zeroTerminated {
+			file = unescapeFilename(file)
+		}
+		known = append(known, &fuzzyHash{hash: h, digest: digest, file: file})
+	}
+	if err := scanner.Err(); err != nil {
+		log.Fatal(err)
+	}
+	return known
+}
+
+// Score the similarity of two fuzzy hashes: from 0 to 100 for ssdeep & the distance for TLSH
+func fuzzyScore(h crypto.Hash, digest1, digest2 string) (score int, match bool) {
+	var err error
+	switch h {
+	case SSDEEP:
+		score, err = ssdeep.Distance(digest1, digest2)
+		match = err == nil && score > opts.ssdeepThreshold
+	case TLSH:
+		if digest1 == tlshNull || digest2 == tlshNull {
+			return 0, false
+		}
+		score, err = tlshDistance(digest1, digest2)
+		match = err == nil && score <= opts.tlshThreshold
+	}
+	return score, match
+}
+
+// Used by the --fuzzy-match option
+func printFuzzyMatches(results *Checksums, known []*fuzzyHash) (matched int) {
+	file := escapeFilename(results.file)
+	for _, checksum := range results.checksums {
+		for _, k := range known {
+			if k.hash != checksum.hash {
+				continue
+			}
+			if score, ok := fuzzyScore(k.hash, string(checksum.sum), k.digest); ok {
+				matched++
+				if !opts.status {
+					fmt.Fprintf(stdout, "%s matches %s (%s %d)\n", file, escapeFilename(k.file), algorithms[k.hash].name, score)
+				}
+			}
+		}
+	}
+	return matched
+}
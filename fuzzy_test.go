@@ -0,0 +1,56 @@
+package main
+
+import (
+	"io"
+	"strings"
+	"testing"
+)
+
+func Test_inputFromFuzzy(t *testing.T) {
+	tlsh := "T14592B76CCFBA760E186391F6AF1F4AD583679F34938560E411FC348A2B0D2BC47EA184"
+	manifest := strings.Join([]string{
+		"ssdeep,1.1--blocksize:hash:hash,filename",
+		`3:Xn:X,"/tmp/a file"`,
+		"3:Xn:X  b",
+		tlsh[2:] + "\tc",
+		"TLSH (d) = " + tlsh,
+		"invalid",
+	}, "\n")
+	known := inputFromFuzzy(io.NopCloser(strings.NewReader(manifest)), false, ErrorIgnore)
+	want := []fuzzyHash{
+		{SSDEEP, "3:Xn:X", "/tmp/a file"},
+		{SSDEEP, "3:Xn:X", "b"},
+		{TLSH, tlsh, "c"},
+		{TLSH, tlsh, "d"},
+	}
+	if len(known) != len(want) {
+		t.Fatalf("inputFromFuzzy() got %d hashes; want %d", len(known), len(want))
+	}
+	for i := range want {
+		if *known[i] != want[i] {
+			t.Errorf("inputFromFuzzy() got %v; want %v", *known[i], want[i])
+		}
+	}
+}
+
+func Test_fuzzyScore(t *testing.T) {
+	oldOpts := opts
+	defer func() { opts = oldOpts }()
+	opts.ssdeepThreshold = 0
+	opts.tlshThreshold = 100
+
+	a := "384:xxVEwJkEtsqVpbTo0/RUTIsGxHp0g+Hk7OICfyE5fK0NXwjbT:xROEfo0/R0IseCICqEFKwXwjn"
+	b := "384:xxVEwJkEtsqVpbT50/RUTIsGxHp0g+Hk7OICfyE5fK0NXwjbT:xROEf50/R0IseCICqEFKwXwjn"
+	if score, ok := fuzzyScore(SSDEEP, a, a); !ok || score != 100 {
+		t.Errorf("fuzzyScore() of the same SSDEEP got %d", score)
+	}
+	if score, ok := fuzzyScore(SSDEEP, a, b); !ok || score == 100 {
+		t.Errorf("fuzzyScore() of similar SSDEEP got %d", score)
+	}
+	if _, ok := fuzzyScore(SSDEEP, a, "3:Xn:X"); ok {
+		t.Error("fuzzyScore() of different block sizes should not match")
+	}
+	if _, ok := fuzzyScore(TLSH, tlshNull, tlshNull); ok {
+		t.Error("fuzzyScore() of TNULL should not match")
+	}
+}
@@ -0,0 +1,242 @@
+package main
+
+import (
+	"crypto"
+	"fmt"
+	"io"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"strings"
+	"sync"
+
+	"golang.org/x/sync/errgroup"
+)
+
+// Object formats supported by git
+var gitHashes = []crypto.Hash{crypto.SHA1, crypto.SHA256}
+
+// Modes as canonicalized by git
+const (
+	gitModeFile       = "100644"
+	gitModeExecutable = "100755"
+	gitModeSymlink    = "120000"
+	gitModeTree       = "40000"
+)
+
+type gitEntry struct {
+	mode  string
+	name  string
+	sums  [][]byte // One per chosen algorithm
+	trees map[string]*gitEntry
+}
+
+// Hash an object as git hash-object does, prepending the "<type> <size>\0" header
+func gitObject(kind string, size Size, r io.Reader, checksums []*Checksum) ([]*Checksum, error) {
+	header := strings.NewReader(fmt.Sprintf("%s %d\x00", kind, size))
+	checksums, n := hashF(io.NopCloser(io.MultiReader(header, r)), checksums)
+	if checksums == nil {
+		return nil, fmt.Errorf("error hashing %s object", kind)
+	}
+	if n != Size(header.Size())+size {
+		return nil, fmt.Errorf("size changed while hashing %s object", kind)
+	}
+	return checksums, nil
+}
+
+func gitSums(checksums []*Checksum) [][]byte {
+	sums := make([][]byte, len(checksums))
+	for i := range checksums {
+		sums[i] = checksums[i].sum
+	}
+	return sums
+}
+
+// Hash a file or a symbolic link as a blob, returning its mode
+func gitBlob(file string, follow bool) (string, []*Checksum, error) {
+	stat := os.Lstat
+	if follow {
+		stat = os.Stat
+	}
+	info, err := stat(file)
+	if err != nil {
+		return "", nil, err
+	}
+	if info.Mode()&fs.ModeSymlink != 0 {
+		target, err := os.Readlink(file)
+		if err != nil {
+			return "", nil, err
+		}
+		checksums, err := gitObject("blob", Size(len(target)), strings.NewReader(target), nil)
+		return gitModeSymlink, checksums, err
+	}
+	if !info.Mode().IsRegular() {
+		return "", nil, fmt.Errorf("%s is not a regular file", file)
+	}
+	f, err := os.Open(file)
+	if err != nil {
+		return "", nil, err
+	}
+	defer f.Close()
+	mode := gitModeFile
+	if info.Mode()&0o100 != 0 {
+		mode = gitModeExecutable
+	}
+	checksums, err := gitObject("blob", info.Size(), f, nil)
+	return mode, checksums, err
+}
+
+// Git sorts tree entries as if directory names had a trailing slash
+func gitSortKey(entry *gitEntry) string {
+	if entry.mode == gitModeTree {
+		return entry.name + "/"
+	}
+	return entry.name
+}
+
+// Compute the tree objects bottom-up
+func (tree *gitEntry) hash() error {
+	entries := make([]*gitEntry, 0, len(tree.trees))
+	for _, entry := range tree.trees {
+		if entry.mode == gitModeTree {
+			if err := entry.hash(); err != nil {
+				return err
+			}
+		}
+		entries = append(entries, entry)
+	}
+	slices.SortFunc(entries, func(a, b *gitEntry) int { return strings.Compare(gitSortKey(a), gitSortKey(b)) })
+
+	tree.sums = make([][]byte, len(chosen))
+	for i, h := range chosen {
+		var buf strings.Builder
+		for _, entry := range entries {
+			fmt.Fprintf(&buf, "%s %s\x00%s", entry.mode, entry.name, entry.sums[i])
+		}
+		checksums, err := gitObject("tree", Size(buf.Len()), strings.NewReader(buf.String()), []*Checksum{{hash: h}})
+		if err != nil {
+			return err
+		}
+		tree.sums[i] = checksums[0].sum
+	}
+	return nil
+}
+
+// Hash a directory as git write-tree would after adding all files to the index
+func gitTree(dir string) ([][]byte, error) {
+	root := &gitEntry{mode: gitModeTree, trees: make(map[string]*gitEntry)}
+
+	var mutex sync.Mutex
+	g := new(errgroup.Group)
+	g.SetLimit(runtime.NumCPU())
+
+	// Symbolic links are stored as blobs so we need them listed
+	walkErr := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+		components := strings.Split(filepath.ToSlash(rel), "/")
+		if slices.Contains(components, ".git") {
+			return nil
+		}
+		g.Go(func() error {
+			mode, checksums, err := gitBlob(path, false)
+			if err != nil {
+				return err
+			}
+			mutex.Lock()
+			defer mutex.Unlock()
+			tree := root
+			for _, name := range components[:len(components)-1] {
+				if tree.trees[name] == nil {
+					tree.trees[name] = &gitEntry{mode: gitModeTree, name: name, trees: make(map[string]*gitEntry)}
+				}
+				tree = tree.trees[name]
+			}
+			name := components[len(components)-1]
+			tree.trees[name] = &gitEntry{mode: mode, name: name, sums: gitSums(checksums)}
+			return nil
+		})
+		return nil
+	})
+	if err := g.Wait(); err != nil {
+		return nil, err
+	}
+	// A tree missing unreadable entries would have the wrong ID
+	if walkErr != nil {
+		return nil, walkErr
+	}
+
+	if err := root.hash(); err != nil {
+		return nil, err
+	}
+	return root.sums, nil
+}
+
+func gitChecksums(file string, sums [][]byte) *Checksums {
+	checksums := make([]*Checksum, len(chosen))
+	for i, h := range chosen {
+		checksums[i] = &Checksum{hash: h, sum: sums[i]}
+	}
+	return &Checksums{file: file, checksums: checksums}
+}
+
+// Used by the --git option
+func hashGit(file string) (*Checksums, error) {
+	info, err := os.Stat(file)
+	if err != nil {
+		return nil, err
+	}
+	if info.IsDir() {
+		sums, err := gitTree(file)
+		if err != nil {
+			return nil, err
+		}
+		return gitChecksums(file, sums), nil
+	}
+	_, checksums, err := gitBlob(file, true)
+	if err != nil {
+		return nil, err
+	}
+	return &Checksums{file: file, size: info.Size(), checksums: checksums}, nil
+}
+
+func hashGitArgs(args []string) (status int) {
+	if len(args) == 0 {
+		data, err := io.ReadAll(os.Stdin)
+		if err != nil {
+			log.Fatal(err)
+		}
+		checksums, err := gitObject("blob", Size(len(data)), strings.NewReader(string(data)), nil)
+		if err != nil {
+			log.Fatal(err)
+		}
+		printChecksums(&Checksums{size: Size(len(data)), checksums: checksums}, opts)
+		return 0
+	}
+	for _, arg := range args {
+		if opts.str {
+			checksums, err := gitObject("blob", Size(len(arg)), strings.NewReader(arg), nil)
+			if err != nil {
+				log.Fatal(err)
+			}
+			printChecksums(&Checksums{file: `"` + arg + `"`, size: Size(len(arg)), checksums: checksums}, opts)
+			continue
+		}
+		results, err := hashGit(arg)
+		if err != nil {
+			log.Print(err)
+			status = 1
+			continue
+		}
+		printChecksums(results, opts)
+	}
+	return status
+}
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"crypto"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func Test_hashGit(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"x":     "hi\n",
+		"a/y":   "y\n",
+		"a/b/z": "z",
+		"a.txt": "q\n",
+		"c/w":   "w\n",
+	}
+	for file, data := range files {
+		file = filepath.Join(dir, file)
+		if err := os.MkdirAll(filepath.Dir(file), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(file, []byte(data), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.Chmod(filepath.Join(dir, "a/y"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink("../x", filepath.Join(dir, "a/lnk")); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.MkdirAll(filepath.Join(dir, ".git/objects"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, ".git/HEAD"), []byte("ref: refs/heads/main\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	oldChosen := chosen
+	defer func() { chosen = oldChosen }()
+	chosen = []crypto.Hash{crypto.SHA1, crypto.SHA256}
+
+	// Values from git hash-object & git write-tree with --object-format=sha1 & sha256
+	xwant := map[string][]string{
+		".": {
+			"947c0c47d587bc0f48206de7ada7cb4f21085449",
+			"50de0fd1c18392b08345a1d7dbdec7027b0a5c8d0bdeb4dff2cea8b11b62a387",
+		},
+		"x": {
+			"45b983be36b73c0788dc9cbcb76cbb80fc7bb057",
+			"96c18f0297e38d01f4b2dacddea4259aea6b2961eb0822bd2c0c3f6029030045",
+		},
+		"a/y": {
+			"975fbec8256d3e8a3797e7a3611380f27c49f4ac",
+			"44dc634218adec09e34f37839b3840bad8c6103693e9216626b32d00e093fa35",
+		},
+	}
+
+	for file, want := range xwant {
+		got, err := hashGit(filepath.Join(dir, file))
+		if err != nil {
+			t.Fatal(err)
+		}
+		for i := range want {
+			if sum := hex.EncodeToString(got.checksums[i].sum); sum != want[i] {
+				t.Errorf("hashGit(%q) %s got %s; want %s", file, algorithms[chosen[i]].name, sum, want[i])
+			}
+		}
+	}
+}
+
+func Test_gitObject(t *testing.T) {
+	oldChosen := chosen
+	defer func() { chosen = oldChosen }()
+	chosen = []crypto.Hash{crypto.SHA1}
+
+	// The empty blob & the empty tree
+	for kind, want := range map[string]string{
+		"blob": "e69de29bb2d1d6434b8b29ae775ad8c2e48c5391",
+		"tree": "4b825dc642cb6eb9a060e54bf8d69288fbee4904",
+	} {
+		got, err := gitObject(kind, 0, strings.NewReader(""), nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if sum := hex.EncodeToString(got[0].sum); sum != want {
+			t.Errorf("gitObject(%q) got %s; want %s", kind, sum, want)
+		}
+	}
+}
+
+func Test_gitTree(t *testing.T) {
+	oldChosen := chosen
+	defer func() { chosen = oldChosen }()
+	chosen = []crypto.Hash{crypto.SHA1}
+
+	if _, err := gitTree(filepath.Join(t.TempDir(), "missing")); err == nil {
+		t.Error("gitTree() on a missing directory should fail")
+	}
+}
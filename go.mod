@@ -3,7 +3,10 @@ module github.com/ricardobranco777/xhash
 go 1.25.0
 
 require (
+	github.com/glaslos/ssdeep v0.4.0
+	github.com/klauspost/compress v1.18.0
 	github.com/spf13/pflag v1.0.10
+	github.com/ulikunitz/xz v0.5.15
 	github.com/zeebo/blake3 v0.2.4
 	golang.org/x/crypto v0.48.0
 	golang.org/x/sync v0.19.0
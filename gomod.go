@@ -0,0 +1,261 @@
+package main
+
+import (
+	"archive/zip"
+	"bufio"
+	"crypto"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+	"unicode"
+)
+
+// Format of go.sum lines
+var goSumRegex = regexp.MustCompile(`^(\S+) (\S+?)(/go\.mod)? h1:([A-Za-z0-9+/]+={0,2})$`)
+
+// Hash1 from golang.org/x/mod/sumdb/dirhash without the "h1:" prefix & Base64 encoding
+func goHash1(files []string, open func(string) (io.ReadCloser, error)) ([]byte, error) {
+	h := sha256.New()
+	files = slices.Clone(files)
+	slices.Sort(files)
+	for _, file := range files {
+		if strings.Contains(file, "\n") {
+			return nil, fmt.Errorf("dirhash: filenames with newlines are not supported")
+		}
+		r, err := open(file)
+		if err != nil {
+			return nil, err
+		}
+		hf := sha256.New()
+		_, err = io.Copy(hf, r)
+		r.Close()
+		if err != nil {
+			return nil, err
+		}
+		fmt.Fprintf(h, "%x  %s\n", hf.Sum(nil), file)
+	}
+	return h.Sum(nil), nil
+}
+
+func goHash1String(sum []byte) string {
+	return "h1:" + base64.StdEncoding.EncodeToString(sum)
+}
+
+// Hash the files of an unpacked module prefixing them with module@version
+func goHashDir(dir string, prefix string) ([]byte, error) {
+	var files []string
+	osfiles := make(map[string]string)
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() || d.Type()&fs.ModeType == fs.ModeSymlink {
+			return err
+		}
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+		file := filepath.ToSlash(filepath.Join(prefix, rel))
+		files = append(files, file)
+		osfiles[file] = path
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return goHash1(files, func(name string) (io.ReadCloser, error) { return os.Open(osfiles[name]) })
+}
+
+// Hash the files of a module zip whose names are already prefixed with module@version
+func goHashZip(file string) ([]byte, error) {
+	z, err := zip.OpenReader(file)
+	if err != nil {
+		return nil, err
+	}
+	defer z.Close()
+	var files []string
+	zfiles := make(map[string]*zip.File)
+	for _, file := range z.File {
+		files = append(files, file.Name)
+		zfiles[file.Name] = file
+	}
+	return goHash1(files, func(name string) (io.ReadCloser, error) {
+		if f, ok := zfiles[name]; ok {
+			return f.Open()
+		}
+		return nil, fmt.Errorf("file %q not found in zip", name)
+	})
+}
+
+// Hash a go.mod file as stored in go.sum
+func goHashMod(file string) ([]byte, error) {
+	return goHash1([]string{"go.mod"}, func(string) (io.ReadCloser, error) { return os.Open(file) })
+}
+
+// The module cache escapes uppercase letters as "!" followed by the lowercase letter
+func goEscapePath(path string) string {
+	var buf strings.Builder
+	for _, r := range path {
+		if unicode.IsUpper(r) {
+			buf.WriteByte('!')
+			r = unicode.ToLower(r)
+		}
+		buf.WriteRune(r)
+	}
+	return buf.String()
+}
+
+func goUnescapePath(path string) string {
+	var buf strings.Builder
+	bang := false
+	for _, r := range path {
+		if r == '!' {
+			bang = true
+			continue
+		}
+		if bang {
+			r = unicode.ToUpper(r)
+			bang = false
+		}
+		buf.WriteRune(r)
+	}
+	return buf.String()
+}
+
+// Same logic as "go env GOMODCACHE"
+func goModCache() string {
+	if dir := os.Getenv("GOMODCACHE"); dir != "" {
+		return dir
+	}
+	if gopath := filepath.SplitList(os.Getenv("GOPATH")); len(gopath) > 0 && gopath[0] != "" {
+		return filepath.Join(gopath[0], "pkg", "mod")
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(home, "go", "pkg", "mod")
+}
+
+// Used by the --dirhash option on directories and module zip files
+func hashGoModule(file string, _ []*Checksum) (*Checksums, error) {
+	info, err := os.Stat(file)
+	if err != nil {
+		return nil, err
+	}
+	var sum []byte
+	if info.IsDir() {
+		// Get module@version from --module or the path in the module cache
+		prefix := opts.module
+		if prefix == "" {
+			if abs, err := filepath.Abs(file); err == nil {
+				rel, err := filepath.Rel(opts.modcache, abs)
+				if module, _, _ := strings.Cut(filepath.Base(rel), "@"); err == nil && filepath.IsLocal(rel) && module != filepath.Base(rel) && module != "" {
+					prefix = goUnescapePath(filepath.ToSlash(rel))
+				}
+			}
+		}
+		if prefix == "" {
+			return nil, fmt.Errorf("%s is not in the module cache: use --module", file)
+		}
+		sum, err = goHashDir(file, prefix)
+	} else {
+		sum, err = goHashZip(file)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &Checksums{
+		file: file,
+		checksums: []*Checksum{
+			{
+				hash: crypto.SHA256,
+				sum:  sum,
+			},
+		},
+	}, nil
+}
+
+// Verify the modules in a go.sum against the module cache
+func inputFromGoSum(f io.ReadCloser, onError ErrorAction) <-chan *Checksums {
+	files := make(chan *Checksums, chanSize)
+
+	go func() {
+		defer close(files)
+		defer f.Close()
+
+		scanner := bufio.NewScanner(f)
+		var lineno uint64
+		for scanner.Scan() {
+			lineno++
+			match := goSumRegex.FindStringSubmatch(scanner.Text())
+			if match == nil {
+				switch onError {
+				case ErrorWarn:
+					log.Printf("invalid go.sum line %d", lineno)
+				case ErrorExit:
+					log.Fatalf("invalid go.sum line %d", lineno)
+				}
+				continue
+			}
+			module, version, gomod := match[1], match[2], match[3]
+			sum, err := base64.StdEncoding.DecodeString(match[4])
+			if err != nil || len(sum) != sha256.Size {
+				switch onError {
+				case ErrorWarn:
+					log.Printf("invalid digest at line %d", lineno)
+				case ErrorExit:
+					log.Fatalf("invalid digest at line %d", lineno)
+				}
+				continue
+			}
+			files <- &Checksums{
+				file: module + "@" + version + gomod,
+				checksums: []*Checksum{
+					{
+						hash: crypto.SHA256,
+						csum: sum,
+					},
+				},
+			}
+		}
+		if err := scanner.Err(); err != nil {
+			log.Fatal(err)
+		}
+	}()
+
+	return files
+}
+
+// Locate a module entry of go.sum in the module cache and hash it
+func hashGoSumEntry(file string, checksums []*Checksum) (*Checksums, error) {
+	name, gomod := strings.CutSuffix(file, "/go.mod")
+	module, version, _ := strings.Cut(name, "@")
+	download := filepath.Join(opts.modcache, "cache", "download", filepath.FromSlash(goEscapePath(module)), "@v", goEscapePath(version))
+
+	var sum []byte
+	var err error
+	if gomod {
+		sum, err = goHashMod(download + ".mod")
+	} else if dir := filepath.Join(opts.modcache, filepath.FromSlash(goEscapePath(module))+"@"+goEscapePath(version)); isDir(dir) {
+		sum, err = goHashDir(dir, name)
+	} else {
+		sum, err = goHashZip(download + ".zip")
+	}
+	if err != nil {
+		return nil, err
+	}
+	checksums[0].sum = sum
+	return &Checksums{file: file, checksums: checksums}, nil
+}
+
+func isDir(path string) bool {
+	info, err := os.Stat(path)
+	return err == nil && info.IsDir()
+}
@@ -0,0 +1,145 @@
+package main
+
+import (
+	"archive/zip"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// Values from golang.org/x/mod/sumdb/dirhash
+var goModFiles = map[string]string{
+	"go.mod":    "module example.com/m\n",
+	"m.go":      "package m\n",
+	"sub/a.txt": "a",
+}
+
+const (
+	goModH1    = "h1:FiT6T0w18yayd/6i3RS/vi3SS9S5qFI3i6aMch2NwBo="
+	goModGoMod = "h1:flS2VctbRrTv+sBE+VKgxx6hlkMGPVz9MGOmzMYFg3k="
+)
+
+// Create a module cache with the module both unpacked and zipped
+func goTestModCache(t *testing.T) string {
+	modcache := t.TempDir()
+	download := filepath.Join(modcache, "cache", "download", "example.com", "m", "@v")
+	if err := os.MkdirAll(download, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	zf, err := os.Create(filepath.Join(download, "v1.0.0.zip"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	z := zip.NewWriter(zf)
+	for name, data := range goModFiles {
+		file := filepath.Join(modcache, "example.com", "m@v1.0.0", name)
+		if err := os.MkdirAll(filepath.Dir(file), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(file, []byte(data), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		w, err := z.Create("example.com/m@v1.0.0/" + name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := w.Write([]byte(data)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := z.Close(); err != nil {
+		t.Fatal(err)
+	}
+	zf.Close()
+	if err := os.WriteFile(filepath.Join(download, "v1.0.0.mod"), []byte(goModFiles["go.mod"]), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return modcache
+}
+
+func Test_hashGoModule(t *testing.T) {
+	oldModcache := opts.modcache
+	defer func() { opts.modcache = oldModcache }()
+	opts.modcache = goTestModCache(t)
+
+	for _, file := range []string{
+		filepath.Join(opts.modcache, "example.com", "m@v1.0.0"),
+		filepath.Join(opts.modcache, "cache", "download", "example.com", "m", "@v", "v1.0.0.zip"),
+	} {
+		got, err := hashGoModule(file, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if sum := goHash1String(got.checksums[0].sum); sum != goModH1 {
+			t.Errorf("hashGoModule(%q) got %s; want %s", file, sum, goModH1)
+		}
+	}
+
+	// Outside the module cache module@version must be given with --module
+	oldModule := opts.module
+	defer func() { opts.module = oldModule }()
+	dir := filepath.Join(opts.modcache, "example.com", "m@v1.0.0")
+	opts.modcache = t.TempDir()
+	if _, err := hashGoModule(dir, nil); err == nil {
+		t.Errorf("hashGoModule(%q) outside the module cache should fail", dir)
+	}
+	opts.module = "example.com/m@v1.0.0"
+	got, err := hashGoModule(dir, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sum := goHash1String(got.checksums[0].sum); sum != goModH1 {
+		t.Errorf("hashGoModule(%q) got %s; want %s", dir, sum, goModH1)
+	}
+}
+
+func Test_goHashDir(t *testing.T) {
+	if _, err := goHashDir(filepath.Join(t.TempDir(), "missing"), "example.com/m@v1.0.0"); err == nil {
+		t.Error("goHashDir() on a missing directory should fail")
+	}
+}
+
+func Test_hashGoSumEntry(t *testing.T) {
+	oldModcache := opts.modcache
+	defer func() { opts.modcache = oldModcache }()
+	opts.modcache = goTestModCache(t)
+
+	gosum := "example.com/m v1.0.0 " + goModH1 + "\nexample.com/m v1.0.0/go.mod " + goModGoMod + "\n"
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	go func() {
+		defer w.Close()
+		_, _ = w.WriteString(gosum)
+	}()
+
+	n := 0
+	for entry := range inputFromGoSum(r, ErrorIgnore) {
+		got, err := hashGoSumEntry(entry.file, entry.checksums)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(got.checksums[0].sum) != string(got.checksums[0].csum) {
+			t.Errorf("hashGoSumEntry(%q) got %s; want %s", entry.file, goHash1String(got.checksums[0].sum), goHash1String(got.checksums[0].csum))
+		}
+		n++
+	}
+	if n != 2 {
+		t.Errorf("inputFromGoSum() got %d entries; want 2", n)
+	}
+}
+
+func Test_goEscapePath(t *testing.T) {
+	for path, want := range map[string]string{
+		"github.com/BurntSushi/toml": "github.com/!burnt!sushi/toml",
+		"golang.org/x/mod":           "golang.org/x/mod",
+	} {
+		if got := goEscapePath(path); got != want {
+			t.Errorf("goEscapePath(%q) got %q; want %q", path, got, want)
+		}
+		if got := goUnescapePath(want); got != path {
+			t.Errorf("goUnescapePath(%q) got %q; want %q", want, got, path)
+		}
+	}
+}
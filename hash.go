@@ -4,8 +4,10 @@ import (
 	"fmt"
 	"golang.org/x/sync/errgroup"
 	"io"
+	"io/fs"
 	"log"
 	"os"
+	"path/filepath"
 )
 
 func hashF(f io.ReadCloser, checksums []*Checksum) ([]*Checksum, Size) {
@@ -70,6 +72,10 @@ func hashF(f io.ReadCloser, checksums []*Checksum) ([]*Checksum, Size) {
 }
 
 func hashFile(file string, checksums []*Checksum) (*Checksums, error) {
+	if opts.offsetStr != "" || opts.lengthStr != "" {
+		return hashRange(file, opts.offset, opts.length, checksums)
+	}
+
 	f, err := os.Open(file)
 	if err != nil {
 		return nil, err
@@ -84,7 +90,18 @@ func hashFile(file string, checksums []*Checksum) (*Checksums, error) {
 		return nil, fmt.Errorf("%s is a directory", file)
 	}
 
-	checksums, size := hashF(f, checksums)
+	var r io.ReadCloser = f
+	if opts.decompress {
+		if r, err = decompress(f); err != nil {
+			return nil, fmt.Errorf("%s: %v", file, err)
+		}
+		defer r.Close()
+	}
+
+	checksums, size := hashF(r, checksums)
+	if checksums == nil {
+		return nil, fmt.Errorf("%s: error hashing", file)
+	}
 	return &Checksums{
 		file:      file,
 		size:      size,
@@ -92,8 +109,38 @@ func hashFile(file string, checksums []*Checksum) (*Checksums, error) {
 	}, nil
 }
 
+// Size of files & block devices
+func fileSize(f *os.File) (Size, error) {
+	info, err := f.Stat()
+	if err != nil {
+		return 0, err
+	}
+	if info.IsDir() {
+		return 0, fmt.Errorf("%s is a directory", f.Name())
+	}
+	if info.Mode().IsRegular() {
+		return info.Size(), nil
+	}
+	size, err := f.Seek(0, io.SeekEnd)
+	if err != nil {
+		return 0, err
+	}
+	_, err = f.Seek(0, io.SeekStart)
+	return size, err
+}
+
 func hashStdin() *Checksums {
-	checksums, size := hashF(os.Stdin, nil)
+	var r io.ReadCloser = os.Stdin
+	if opts.decompress {
+		var err error
+		if r, err = decompress(os.Stdin); err != nil {
+			log.Fatal(err)
+		}
+	}
+	checksums, size := hashF(r, nil)
+	if checksums == nil {
+		os.Exit(1)
+	}
 	return &Checksums{
 		file:      "",
 		size:      size,
@@ -115,3 +162,30 @@ func hashString(str string) *Checksums {
 		checksums: checksums,
 	}
 }
+
+// Atomically replace file with the checksums printed by print
+func writeChecksums(file string, mode fs.FileMode, print func()) error {
+	tmp, err := os.CreateTemp(filepath.Dir(file), "."+filepath.Base(file)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	saved := stdout
+	stdout = tmp
+	print()
+	stdout = saved
+
+	if err := tmp.Chmod(mode); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), file)
+}
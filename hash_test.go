@@ -105,8 +105,8 @@ func BenchmarkHashes(b *testing.B) {
 	for _, h := range hashes {
 		checksum := &Checksum{hash: h}
 		name := h.String()
-		if h == BLAKE3 {
-			name = "BLAKE3"
+		if extra, ok := extraHashes[h]; ok {
+			name = extra
 		}
 		b.Run(name, func(b *testing.B) {
 			for b.Loop() {
@@ -2,6 +2,7 @@ package main
 
 import (
 	"bytes"
+	"cmp"
 	"crypto"
 	"crypto/hmac"
 	_ "crypto/md5"
@@ -12,12 +13,14 @@ import (
 	"encoding/base64"
 	"encoding/hex"
 	"fmt"
+	_ "golang.org/x/crypto/md4"
 	"golang.org/x/sync/errgroup"
 	"io"
 	"log"
 	"os"
 	"path/filepath"
 	"runtime"
+	"slices"
 	"strconv"
 	"strings"
 	"sync/atomic"
@@ -42,10 +45,48 @@ func getOutput(results *Checksums, opts Options) []*Output {
 			Sum:  strconv.FormatInt(results.size, 10),
 		})
 	}
+	if opts.etag {
+		return append(outputs, &Output{
+			File: file,
+			Name: "ETAG",
+			Sum:  string(results.checksums[0].sum),
+		})
+	}
+	if opts.dirhash {
+		return append(outputs, &Output{
+			File: file,
+			Name: "H1",
+			Sum:  goHash1String(results.checksums[0].sum),
+		})
+	}
+	if opts.magnet {
+		return append(outputs, &Output{
+			File: file,
+			Name: "MAGNET",
+			Sum:  magnetLink(results),
+		})
+	}
+	if opts.sri {
+		return append(outputs, &Output{
+			File: file,
+			Name: "SRI",
+			Sum:  sriString(results.checksums),
+		})
+	}
 	for i := range results.checksums {
 		var sum string
-		if opts.base64 {
+		if slices.Contains(fuzzyHashes, results.checksums[i].hash) {
+			sum = string(results.checksums[i].sum)
+		} else if opts.nix32 {
+			sum = nix32Encode(results.checksums[i].sum)
+		} else if opts.cid {
+			sum = multibaseEncode(opts.multibase, cidRawV1(results.checksums[i].hash, results.checksums[i].sum))
+		} else if opts.multihash {
+			sum = multibaseEncode(opts.multibase, multihash(results.checksums[i].hash, results.checksums[i].sum))
+		} else if opts.base64 {
 			sum = base64.StdEncoding.EncodeToString(results.checksums[i].sum)
+		} else if results.checksums[i].hash == TTH {
+			sum = tthEncoding.EncodeToString(results.checksums[i].sum)
 		} else {
 			sum = hex.EncodeToString(results.checksums[i].sum)
 		}
@@ -59,7 +100,20 @@ func getOutput(results *Checksums, opts Options) []*Output {
 }
 
 func printChecksums(results *Checksums, opts Options) {
-	if err := format.Execute(os.Stdout, getOutput(results, opts)); err != nil {
+	if results.pieces != nil {
+		for _, piece := range results.pieces {
+			printChecksums(piece, opts)
+		}
+		return
+	}
+	if opts.cid && results.size > ipfsBlockSize {
+		fmt.Fprintf(os.Stderr, "WARNING: %s is larger than a single IPFS block\n", escapeFilename(results.file))
+	}
+	outputs := getOutput(results, opts)
+	for _, output := range outputs {
+		output.Offset, output.Length = results.offset, results.size
+	}
+	if err := format.Execute(stdout, outputs); err != nil {
 		panic(err)
 	}
 }
@@ -76,18 +130,20 @@ func printCheckResults(results *Checksums) (unmatched int) {
 		if ok {
 			if !opts.quiet && !opts.status {
 				if opts.verbose {
-					fmt.Printf("%s: %s OK\n", file, algorithms[results.checksums[i].hash].name)
+					fmt.Fprintf(stdout, "%s: %s OK\n", file, algorithms[results.checksums[i].hash].name)
 				} else {
-					fmt.Printf("%s: OK\n", file)
+					fmt.Fprintf(stdout, "%s: OK\n", file)
 				}
 			}
 		} else {
 			unmatched++
 			if !opts.status {
-				if opts.verbose {
-					fmt.Printf("%s: %s FAILED with %s\n", file, algorithms[results.checksums[i].hash].name, hex.EncodeToString(results.checksums[i].sum))
+				if opts.verbose && opts.etag {
+					fmt.Fprintf(stdout, "%s: ETAG FAILED with %s\n", file, results.checksums[i].sum)
+				} else if opts.verbose {
+					fmt.Fprintf(stdout, "%s: %s FAILED with %s\n", file, algorithms[results.checksums[i].hash].name, hex.EncodeToString(results.checksums[i].sum))
 				} else {
-					fmt.Printf("%s: FAILED\n", file)
+					fmt.Fprintf(stdout, "%s: FAILED\n", file)
 				}
 			}
 		}
@@ -95,6 +151,61 @@ func printCheckResults(results *Checksums) (unmatched int) {
 	return unmatched
 }
 
+// Options for modes & output formats that only work when hashing whole files, in the order they're reported
+func specialMode(allowed ...string) string {
+	modes := []struct {
+		name string
+		set  bool
+	}{
+		{"--archive", opts.archive},
+		{"--cdc", opts.cdc != nil},
+		{"--check-extra", opts.checkExtra != ""},
+		{"--check-per-dir", opts.checkPerDir != ""},
+		{"--cid", opts.cid},
+		{"--cloud", opts.cloud != ""},
+		{"--compare", opts.compare},
+		{"--copy-to", opts.copyTo != ""},
+		{"--decompress", opts.decompress},
+		{"--dirhash", opts.dirhash},
+		{"--dmverity", opts.dmverity},
+		{"--duplicates", opts.duplicates},
+		{"--etag", opts.etag},
+		{"--fsverity", opts.fsverity},
+		{"--fuzzy-match", opts.fuzzyMatch != ""},
+		{"--git", opts.git},
+		{"--length", opts.lengthStr != ""},
+		{"--magnet", opts.magnet},
+		{"--match", opts.match != ""},
+		{"--multihash", opts.multihash},
+		{"--nar", opts.nar},
+		{"--nix32", opts.nix32},
+		{"--no-match", opts.noMatch != ""},
+		{"--offset", opts.offsetStr != ""},
+		{"--passthrough", opts.passthrough},
+		{"--per-dir", opts.perDir != ""},
+		{"--piece-size", opts.pieceSizeStr != ""},
+		{"--sri", opts.sri},
+		{"--tee", opts.tee != nil},
+		{"--update", opts.update != ""},
+	}
+	for _, mode := range modes {
+		if mode.set && !slices.Contains(allowed, mode.name) {
+			return mode.name
+		}
+	}
+	return ""
+}
+
+// Output formats that may be used by modes printing checksums
+var outputFormats = []string{"--cid", "--magnet", "--multihash", "--nix32", "--sri"}
+
+// Select all algorithms for --all, ignoring those specified & the special ones
+func selectAll() {
+	for h, algo := range algorithms {
+		algo.check = !algo.check && !slices.Contains(specialHashes, h)
+	}
+}
+
 func init() {
 	log.SetPrefix("ERROR: ")
 	log.SetFlags(0)
@@ -118,12 +229,28 @@ func init() {
 		flag.BoolVarP(&opts.base64, "base64", "b", false, "output hash in Base64 encoding format")
 		flag.BoolVarP(&opts.gnu, "gnu", "", false, "output hashes in the format used by md5sum")
 	}
+	flag.BoolVarP(&opts.fsverity, "fsverity", "", false, "output the fs-verity file digest like fsverity digest")
+	flag.StringVarP(&opts.fuzzyMatch, "fuzzy-match", "", "", "score files against the SSDEEP & TLSH hashes in file")
+	flag.BoolVarP(&opts.git, "git", "", false, "output git object IDs of files (blobs) and directories (trees)")
+	flag.StringVarP(&opts.cdcStr, "cdc", "", "", "split files with FastCDC in chunks of MIN:AVG:MAX or AVG sizes and print a deduplication summary")
+	flag.BoolVarP(&opts.archive, "archive", "", false, "hash the members of tar & zip archives as archive!member")
+	flag.BoolVarP(&opts.cid, "cid", "", false, "output hash as a CIDv1 with the raw codec")
+	flag.StringVarP(&opts.cloud, "cloud", "", "", "output or check the hashes used by cloud providers: azure, dropbox, gcs")
+	flag.BoolVarP(&opts.decompress, "decompress", "", false, "hash the decompressed contents of gzip, bzip2, xz & zstd files")
+	flag.BoolVarP(&opts.dirhash, "dirhash", "", false, "output Go module h1: hashes of directories & zip files, or verify go.sum with -c")
 	flag.BoolVarP(&opts.ignore, "ignore-missing", "", false, "don't fail or report status for missing files")
+	flag.BoolVarP(&opts.multihash, "multihash", "", false, "output hash in multihash format")
+	flag.BoolVarP(&opts.dmverity, "dmverity", "", false, "output the dm-verity root hash of images like veritysetup format")
+	flag.BoolVarP(&opts.etag, "etag", "", false, "output or check AWS S3 ETags of multipart uploads")
+	flag.BoolVarP(&opts.nar, "nar", "", false, "hash the Nix ARchive serialisation of files & directories like nix hash path")
+	flag.BoolVarP(&opts.nix32, "nix32", "", false, "output hash in Nix base32 encoding format")
+	flag.StringVarP(&opts.pieceSizeStr, "piece-size", "", "", "hash files in pieces of this size")
 	flag.BoolVarP(&opts.quiet, "quiet", "q", false, "don't print OK for each successfully verified file")
 	flag.BoolVarP(&opts.recursive, "recursive", "r", false, "recurse into directories")
 	flag.BoolVarP(&opts.size, "size", "", false, "output size")
+	flag.BoolVarP(&opts.sri, "sri", "", false, "output or check Subresource Integrity metadata")
 	flag.BoolVarP(&opts.status, "status", "S", false, "don't output anything, status code shows success")
-	flag.BoolVarP(&opts.strict, "strict", "", false, "exit non-zero for improperly formatted checksum lines")
+	flag.BoolVarP(&opts.strict, "strict", "", false, "exit non-zero for improperly formatted checksum lines & files not listed with --check-extra")
 	flag.BoolVarP(&opts.str, "string", "s", false, "treat arguments as strings")
 	flag.BoolVarP(&opts.followSymlinks, "symlinks", "L", false, "follow symbolic links while recursing directories")
 	flag.BoolVarP(&opts.verbose, "verbose", "v", false, "verbose operation")
@@ -133,6 +260,36 @@ func init() {
 	flag.StringVarP(&opts.check, "check", "c", "\x00", "read checksums from file (use \"\" for stdin)")
 	flag.StringVarP(&opts.input, "input", "i", "\x00", "read pathnames from file (use \"\" for stdin)")
 	flag.StringVarP(&opts.key, "hmac", "H", "\x00", "key for HMAC (in hexadecimal) or read from specified pathname")
+	flag.StringVarP(&opts.partSizeStr, "part-size", "", "", "part size used by --etag (default 8MiB, or guessed with -c)")
+	flag.BoolVarP(&opts.magnet, "magnet", "", false, "output magnet links with the TTH (default), ED2K, SHA1 & MD5 hashes")
+	flag.StringVarP(&opts.lengthStr, "length", "", "", "hash only this many bytes of files & block devices")
+	flag.StringVarP(&opts.offsetStr, "offset", "", "", "hash files & block devices from this offset")
+	flag.StringVarP(&opts.output, "output", "o", "", "write checksums & check results to file instead of stdout (or stderr with --tee & -p)")
+	flag.BoolVarP(&opts.passthrough, "passthrough", "p", false, "copy stdin or file to stdout while hashing")
+	flag.StringArrayVarP(&opts.tee, "tee", "", nil, "copy stdin or file to this file while hashing (may be repeated)")
+	flag.StringVarP(&opts.copyTo, "copy-to", "", "", "copy files to directory while hashing them and verify the copies")
+	flag.BoolVarP(&opts.preserve, "preserve", "", false, "preserve mode & modification times with --copy-to")
+	flag.StringVarP(&opts.resume, "resume", "", "", "skip files in this manifest whose copies still verify with --copy-to")
+	flag.BoolVarP(&opts.duplicates, "duplicates", "", false, "print groups of files with identical contents")
+	flag.StringVarP(&opts.dupScript, "duplicates-script", "", "", "print a shell script to hardlink or delete the duplicates found by --duplicates")
+	flag.StringVarP(&opts.match, "match", "m", "", "print only files matching the hashes in file")
+	flag.StringVarP(&opts.noMatch, "no-match", "x", "", "print only files not matching the hashes in file")
+	flag.BoolVarP(&opts.compare, "compare", "", false, "compare the files in two directories by content")
+	flag.StringVarP(&opts.checkExtra, "check-extra", "", "", "report files in directory not listed in the file read by -c")
+	flag.StringVarP(&opts.update, "update", "u", "", "update checksum file in place with new & modified files")
+	flag.BoolVarP(&opts.rehash, "rehash", "", false, "rehash all files with --update instead of those modified after the checksum file")
+	flag.StringVarP(&opts.perDir, "per-dir", "", "", "write a checksum file with this name in each directory with -r")
+	flag.StringVarP(&opts.checkPerDir, "check-per-dir", "", "", "verify the checksum files with this name in directories")
+	flag.StringVarP(&opts.baseDir, "base-dir", "", "", "resolve paths read by -c against this directory")
+	flag.IntVarP(&opts.strip, "strip", "", 0, "strip this many leading components from paths read by -c")
+	flag.BoolVarP(&opts.allowOutside, "allow-outside", "", false, "allow paths read by -c outside --base-dir")
+	flag.StringVarP(&opts.modcache, "modcache", "", goModCache(), "Go module cache directory used by --dirhash")
+	flag.StringVarP(&opts.module, "module", "", "", "module@version of directories outside the module cache used by --dirhash")
+	flag.IntVarP(&opts.ssdeepThreshold, "ssdeep-threshold", "", 0, "minimum SSDEEP score (exclusive) used by --fuzzy-match")
+	flag.IntVarP(&opts.tlshThreshold, "tlsh-threshold", "", 100, "maximum TLSH distance used by --fuzzy-match")
+	flag.IntVarP(&opts.verityBlockSize, "verity-block-size", "", 4096, "block size used by --fsverity & --dmverity")
+	flag.StringVarP(&opts.veritySaltStr, "verity-salt", "", "", "salt in hexadecimal used by --fsverity & --dmverity")
+	flag.StringVarP(&opts.multibase, "multibase", "", "", "multibase encoding for --multihash & --cid: base16, base32, base32upper, base58btc, base64, base64url")
 	if strings.Contains(progname, "sum") {
 		flag.StringVarP(&opts.format, "format", "f", gnuFormat, "output format")
 	} else {
@@ -157,8 +314,8 @@ func init() {
 
 	algorithms = make(map[crypto.Hash]*Algorithm)
 	for _, h := range hashes {
-		if h == BLAKE3 {
-			algorithms[h] = &Algorithm{name: "BLAKE3"}
+		if name, ok := extraHashes[h]; ok {
+			algorithms[h] = &Algorithm{name: name}
 		} else if h.Available() {
 			algorithms[h] = &Algorithm{
 				name: strings.ReplaceAll(strings.ReplaceAll(h.String(), "SHA-", "SHA"), "/", "-"),
@@ -196,31 +353,368 @@ func init() {
 
 	if strings.HasPrefix(progname, "xhash") {
 		if opts.all {
-			// Ignore algorithm if --all was specified
-			for h := range algorithms {
-				algorithms[h].check = !algorithms[h].check
-			}
+			selectAll()
 		}
 
 		// Initialize chosen and populate name2Hash
 		for _, h := range hashes {
-			available := h == BLAKE3 || h.Available()
+			_, extra := extraHashes[h]
+			available := extra || h.Available()
 			if available && algorithms[h].check {
 				chosen = append(chosen, h)
 			}
 			name2Hash[algorithms[h].name] = h
 		}
 
-		if opts.check == "\x00" && len(chosen) == 0 {
-			// SHA-256 is default
-			chosen = append(chosen, crypto.SHA256)
+		// With --match, --no-match & --update the default is the algorithms in the file
+		if opts.check == "\x00" && opts.match == "" && opts.noMatch == "" && opts.update == "" && opts.checkPerDir == "" && len(chosen) == 0 {
+			if opts.sri && !opts.nar {
+				// SHA-384 is the most common for SRI
+				chosen = append(chosen, crypto.SHA384)
+			} else if opts.git {
+				// SHA-1 is the default object format
+				chosen = append(chosen, crypto.SHA1)
+			} else if opts.fuzzyMatch != "" {
+				chosen = append(chosen, fuzzyHashes...)
+			} else if opts.magnet {
+				// Tiger Tree Hash is the most common in magnet links
+				chosen = append(chosen, TTH)
+			} else {
+				// SHA-256 is default
+				chosen = append(chosen, crypto.SHA256)
+			}
 		}
 	} else {
 		chosen = []crypto.Hash{hashes[0]}
 		name2Hash[algorithms[hashes[0]].name] = hashes[0]
 	}
 
+	if opts.sri {
+		if opts.key != "\x00" {
+			log.Fatal("The --sri & --hmac options are mutually exclusive")
+		}
+		for _, h := range chosen {
+			if !slices.Contains(sriHashes, h) {
+				log.Fatalf("%s is not supported by --sri", algorithms[h].name)
+			}
+		}
+	}
+
+	if opts.etag {
+		if opts.key != "\x00" {
+			log.Fatal("The --etag & --hmac options are mutually exclusive")
+		}
+		if flag.NArg() == 0 && opts.check == "\x00" && opts.input == "\x00" || opts.str {
+			log.Fatal("The --etag option needs files")
+		}
+	}
+	if opts.partSizeStr != "" {
+		var err error
+		if opts.partSize, err = parseSize(opts.partSizeStr); err != nil || opts.partSize <= 0 {
+			log.Fatalf("Invalid part size: %s", opts.partSizeStr)
+		}
+	}
+
+	if opts.pieceSizeStr != "" {
+		var err error
+		if opts.pieceSize, err = parseSize(opts.pieceSizeStr); err != nil || opts.pieceSize <= 0 {
+			log.Fatalf("Invalid piece size: %s", opts.pieceSizeStr)
+		}
+		if opts.check != "\x00" || opts.str || flag.NArg() == 0 && opts.input == "\x00" {
+			log.Fatal("The --piece-size option needs files")
+		}
+	}
+
+	if opts.cdcStr != "" {
+		var err error
+		if opts.cdc, err = parseCDC(opts.cdcStr); err != nil {
+			log.Fatal(err)
+		}
+		if opts.pieceSizeStr != "" {
+			log.Fatal("The --cdc & --piece-size options are mutually exclusive")
+		}
+		if opts.check != "\x00" || opts.str || flag.NArg() == 0 && opts.input == "\x00" {
+			log.Fatal("The --cdc option needs files")
+		}
+	}
+
+	if opts.fsverity || opts.dmverity {
+		if opts.fsverity && opts.dmverity {
+			log.Fatal("The --fsverity & --dmverity options are mutually exclusive")
+		}
+		if opts.key != "\x00" {
+			log.Fatal("The --fsverity & --dmverity options are incompatible with --hmac")
+		}
+		if opts.check != "\x00" || opts.str || flag.NArg() == 0 && opts.input == "\x00" {
+			log.Fatal("The --fsverity & --dmverity options need files")
+		}
+		for _, h := range chosen {
+			if _, ok := fsverityHashes[h]; opts.fsverity && !ok || opts.dmverity && !slices.Contains(dmverityHashes, h) {
+				log.Fatalf("%s is not supported by --fsverity & --dmverity", algorithms[h].name)
+			}
+		}
+		if opts.verityBlockSize < 1024 || opts.verityBlockSize > 65536 || opts.verityBlockSize&(opts.verityBlockSize-1) != 0 {
+			log.Fatalf("Invalid block size: %d", opts.verityBlockSize)
+		}
+		var err error
+		if opts.veritySalt, err = hex.DecodeString(opts.veritySaltStr); err != nil || opts.fsverity && len(opts.veritySalt) > 32 {
+			log.Fatalf("Invalid salt: %s", opts.veritySaltStr)
+		}
+	}
+
+	if opts.fuzzyMatch != "" {
+		if opts.check != "\x00" {
+			log.Fatal("The --fuzzy-match & --check options are mutually exclusive")
+		}
+		if opts.str || flag.NArg() == 0 && opts.input == "\x00" {
+			log.Fatal("The --fuzzy-match option needs files")
+		}
+		for _, h := range chosen {
+			if !slices.Contains(fuzzyHashes, h) {
+				log.Fatalf("%s is not supported by --fuzzy-match", algorithms[h].name)
+			}
+		}
+	} else if opts.check != "\x00" {
+		for _, h := range chosen {
+			if slices.Contains(fuzzyHashes, h) {
+				log.Fatalf("%s can't be checked for equality: use --fuzzy-match", algorithms[h].name)
+			}
+		}
+	}
+
+	if opts.magnet {
+		if opts.check != "\x00" {
+			log.Fatal("The --magnet & --check options are mutually exclusive")
+		}
+		for _, h := range chosen {
+			if _, ok := magnetTopics[h]; !ok {
+				log.Fatalf("%s is not supported by --magnet", algorithms[h].name)
+			}
+		}
+	}
+
+	if opts.offsetStr != "" || opts.lengthStr != "" {
+		if opts.check != "\x00" {
+			log.Fatal("The --offset & --length options are incompatible with --check: ranges are read from the file")
+		}
+		if opts.str || flag.NArg() == 0 && opts.input == "\x00" {
+			log.Fatal("The --offset & --length options need files")
+		}
+		if mode := specialMode(slices.Concat(outputFormats, []string{"--offset", "--length"})...); mode != "" {
+			log.Fatalf("The --offset & --length options can't be used with %s", mode)
+		}
+		var err error
+		if opts.offsetStr != "" {
+			if opts.offset, err = parseSize(opts.offsetStr); err != nil || opts.offset < 0 {
+				log.Fatalf("Invalid offset: %s", opts.offsetStr)
+			}
+		}
+		opts.length = -1
+		if opts.lengthStr != "" {
+			if opts.length, err = parseSize(opts.lengthStr); err != nil || opts.length <= 0 {
+				log.Fatalf("Invalid length: %s", opts.lengthStr)
+			}
+		}
+	}
+
+	// These read files themselves instead of through hashFile
+	if opts.decompress && (opts.pieceSizeStr != "" || opts.cdc != nil || opts.nar || opts.etag || opts.dirhash || opts.fsverity || opts.dmverity || opts.git) {
+		log.Fatal("The --decompress option only works when hashing whole files")
+	}
+
+	if opts.passthrough || opts.tee != nil {
+		if opts.check != "\x00" || opts.input != "\x00" || opts.recursive || opts.str || flag.NArg() > 1 {
+			log.Fatal("The --tee & -p options need stdin or a single file")
+		}
+		if mode := specialMode(slices.Concat(outputFormats, []string{"--tee", "--passthrough", "--decompress"})...); mode != "" {
+			log.Fatalf("The --tee & -p options can't be used with %s", mode)
+		}
+	}
+
+	if opts.copyTo != "" {
+		if opts.check != "\x00" || opts.input != "\x00" || opts.str || flag.NArg() == 0 {
+			log.Fatal("The --copy-to option needs files or directories")
+		}
+		if mode := specialMode("--copy-to"); mode != "" {
+			log.Fatalf("The --copy-to option can't be used with %s", mode)
+		}
+	} else if opts.preserve || opts.resume != "" {
+		log.Fatal("The --preserve & --resume options need --copy-to")
+	}
+
+	if opts.duplicates {
+		if opts.check != "\x00" || opts.str || flag.NArg() == 0 && opts.input == "\x00" {
+			log.Fatal("The --duplicates option needs files or directories")
+		}
+		if mode := specialMode(slices.Concat(outputFormats, []string{"--duplicates"})...); mode != "" {
+			log.Fatalf("The --duplicates option can't be used with %s", mode)
+		}
+		if opts.dupScript != "" && opts.dupScript != "hardlink" && opts.dupScript != "delete" {
+			log.Fatalf("Invalid duplicates script: %s", opts.dupScript)
+		}
+	} else if opts.dupScript != "" {
+		log.Fatal("The --duplicates-script option needs --duplicates")
+	}
+
+	if opts.match != "" || opts.noMatch != "" {
+		if opts.match != "" && opts.noMatch != "" {
+			log.Fatal("The --match & --no-match options are mutually exclusive")
+		}
+		if opts.check != "\x00" || opts.str || flag.NArg() == 0 && opts.input == "\x00" {
+			log.Fatal("The --match & --no-match options need files or directories")
+		}
+		if mode := specialMode(slices.Concat(outputFormats, []string{"--match", "--no-match", "--decompress"})...); mode != "" {
+			log.Fatalf("The --match & --no-match options can't be used with %s", mode)
+		}
+	}
+
+	if opts.compare {
+		if opts.check != "\x00" || opts.input != "\x00" || opts.str || flag.NArg() != 2 {
+			log.Fatal("The --compare option needs two directories")
+		}
+		if mode := specialMode("--compare"); mode != "" {
+			log.Fatalf("The --compare option can't be used with %s", mode)
+		}
+	}
+
+	if opts.checkExtra != "" && (opts.check == "\x00" || opts.dirhash) {
+		log.Fatal("The --check-extra option needs -c with files")
+	}
+
+	if opts.update != "" {
+		if opts.check != "\x00" || opts.str || opts.output != "" {
+			log.Fatal("The --update option is incompatible with -c, -s & -o")
+		}
+		if mode := specialMode("--update"); mode != "" {
+			log.Fatalf("The --update option can't be used with %s", mode)
+		}
+		// Preserve the format of the checksum file, including sizes
+		gnu, isBase64, size := manifestFormat(opts.update)
+		if !flag.CommandLine.Changed("format") && !opts.gnu && !opts.base64 {
+			opts.gnu, opts.base64 = gnu, isBase64
+		}
+		opts.size = opts.size || size
+	} else if opts.rehash {
+		log.Fatal("The --rehash option needs --update")
+	}
+
+	if opts.perDir != "" || opts.checkPerDir != "" {
+		if opts.perDir != "" && opts.checkPerDir != "" {
+			log.Fatal("The --per-dir & --check-per-dir options are mutually exclusive")
+		}
+		if opts.check != "\x00" || opts.input != "\x00" || opts.str || opts.output != "" || flag.NArg() == 0 {
+			log.Fatal("The --per-dir & --check-per-dir options need directories")
+		}
+		if opts.perDir != "" && !opts.recursive {
+			log.Fatal("The --per-dir option needs -r")
+		}
+		for _, name := range []string{opts.perDir, opts.checkPerDir} {
+			if name != "" && filepath.Base(name) != name {
+				log.Fatalf("Invalid checksum file name: %s", name)
+			}
+		}
+		if mode := specialMode("--per-dir", "--check-per-dir", "--decompress"); mode != "" {
+			log.Fatalf("The --per-dir & --check-per-dir options can't be used with %s", mode)
+		}
+	}
+
+	if opts.baseDir != "" || opts.strip != 0 || opts.allowOutside {
+		if opts.check == "\x00" || opts.dirhash {
+			log.Fatal("The --base-dir, --strip & --allow-outside options need -c with files")
+		}
+		if opts.strip < 0 {
+			log.Fatalf("Invalid number of path components: %d", opts.strip)
+		}
+		if opts.baseDir != "" {
+			if info, err := os.Stat(opts.baseDir); err != nil {
+				log.Fatal(err)
+			} else if !info.IsDir() {
+				log.Fatalf("%s is not a directory", opts.baseDir)
+			}
+		}
+	}
+
+	if opts.nar && (opts.check != "\x00" || flag.NArg() == 0 || opts.str) {
+		log.Fatal("The --nar option needs files or directories")
+	}
+
+	if opts.dirhash {
+		if opts.key != "\x00" {
+			log.Fatal("The --dirhash & --hmac options are mutually exclusive")
+		}
+		if opts.check == "\x00" && opts.input == "\x00" && flag.NArg() == 0 {
+			log.Fatal("The --dirhash option needs directories or zip files")
+		}
+	}
+	if opts.module != "" {
+		if !opts.dirhash || opts.check != "\x00" {
+			log.Fatal("The --module option needs --dirhash with directories")
+		}
+		if module, version, ok := strings.Cut(opts.module, "@"); !ok || module == "" || version == "" {
+			log.Fatalf("Invalid module@version: %s", opts.module)
+		}
+	}
+
+	if opts.cloud != "" {
+		cloud, ok := clouds[opts.cloud]
+		if !ok {
+			log.Fatalf("Invalid cloud provider: %s", opts.cloud)
+		}
+		// The algorithms of cloud providers are only registered when running as xhash
+		if !strings.HasPrefix(progname, "xhash") {
+			log.Fatalf("The --cloud option doesn't work when running as %s", progname)
+		}
+		if opts.key != "\x00" {
+			log.Fatal("The --cloud & --hmac options are mutually exclusive")
+		}
+		if opts.all || slices.ContainsFunc(hashes, func(h crypto.Hash) bool { return algorithms[h].check }) {
+			log.Fatal("The --cloud option can't be used with other algorithms")
+		}
+		chosen = cloud.hashes
+		opts.base64 = cloud.base64
+	}
+
+	if opts.git {
+		if opts.key != "\x00" {
+			log.Fatal("The --git & --hmac options are mutually exclusive")
+		}
+		if opts.check != "\x00" || opts.input != "\x00" {
+			log.Fatal("The --git option needs files, directories or strings")
+		}
+		for _, h := range chosen {
+			if !slices.Contains(gitHashes, h) {
+				log.Fatalf("%s is not supported by --git", algorithms[h].name)
+			}
+		}
+	}
+
+	if opts.multihash || opts.cid {
+		if opts.key != "\x00" {
+			log.Fatal("The --multihash & --cid options are incompatible with --hmac")
+		}
+		if opts.multibase == "" {
+			// Defaults used by IPFS
+			if opts.cid {
+				opts.multibase = "base32"
+			} else {
+				opts.multibase = "base58btc"
+			}
+		} else if _, ok := multibases[opts.multibase]; !ok {
+			log.Fatalf("Invalid multibase encoding: %s", opts.multibase)
+		}
+		for _, h := range chosen {
+			if _, ok := hash2multicodec[h]; !ok {
+				log.Fatalf("%s is not supported by --multihash & --cid", algorithms[h].name)
+			}
+		}
+	}
+
 	if opts.key != "\x00" {
+		for _, h := range chosen {
+			if slices.Contains(specialHashes, h) {
+				log.Fatalf("%s is not supported by --hmac", algorithms[h].name)
+			}
+		}
 		var err error
 		if opts.key == "" {
 			if macKey, err = io.ReadAll(os.Stdin); err != nil {
@@ -253,6 +747,16 @@ func init() {
 	}
 }
 
+// Action for improperly formatted lines
+func errorAction() ErrorAction {
+	if opts.warn {
+		return ErrorWarn
+	} else if opts.strict {
+		return ErrorExit
+	}
+	return ErrorIgnore
+}
+
 func openFileOrStdin(filename string) io.ReadCloser {
 	var err error
 	f := os.Stdin
@@ -265,17 +769,134 @@ func openFileOrStdin(filename string) io.ReadCloser {
 }
 
 func main() {
+	// Read before -o truncates it, as the manifest may be the same file
+	var manifest map[string]*Checksums
+	if opts.resume != "" {
+		manifest = readManifest(opts.resume)
+	}
+
+	if opts.output != "" {
+		f, err := os.Create(opts.output)
+		if err != nil {
+			log.Fatal(err)
+		}
+		defer f.Close()
+		stdout = f
+	} else if opts.passthrough || opts.tee != nil {
+		stdout = os.Stderr
+	}
+
+	if opts.git {
+		os.Exit(hashGitArgs(flag.Args()))
+	}
+
+	if opts.copyTo != "" {
+		if copyFiles(flag.Args(), opts.copyTo, manifest) > 0 {
+			os.Exit(1)
+		}
+		os.Exit(0)
+	}
+
+	if opts.passthrough || opts.tee != nil {
+		results, err := hashTee(flag.Arg(0), opts.tee, opts.passthrough)
+		if err != nil {
+			log.Fatal(err)
+		}
+		printChecksums(results, opts)
+		os.Exit(0)
+	}
+
+	if opts.perDir != "" {
+		if writePerDir(flag.Args(), opts.perDir) > 0 {
+			os.Exit(1)
+		}
+		os.Exit(0)
+	}
+
+	if opts.update != "" {
+		var files <-chan *Checksums
+		if opts.input != "\x00" {
+			f := openFileOrStdin(opts.input)
+			defer f.Close()
+			files = inputFromFile(f, opts.zero)
+		} else if opts.recursive {
+			files = inputFromDir(flag.Args(), opts.followSymlinks)
+		} else {
+			files = inputFromArgs(flag.Args())
+		}
+		if err := updateManifest(opts.update, files); err != nil {
+			log.Fatal(err)
+		}
+		os.Exit(0)
+	}
+
+	if opts.compare {
+		left, right := flag.Arg(0), flag.Arg(1)
+		results, err := compareTrees(left, right)
+		if err != nil {
+			log.Print(err)
+			os.Exit(2)
+		}
+		os.Exit(printCompareResults(stdout, results, left, right))
+	}
+
+	var known hashSet
+	if file := cmp.Or(opts.match, opts.noMatch); file != "" {
+		known = inputFromHashSet(openFileOrStdin(file), opts.zero, errorAction())
+		if len(known) == 0 {
+			log.Fatalf("No valid hashes in %s", file)
+		}
+		if len(chosen) == 0 {
+			chosen = known.hashes()
+		}
+	}
+
 	var lines <-chan *Checksums
-	if opts.check != "\x00" {
-		onError := ErrorIgnore
-		if opts.warn {
-			onError = ErrorWarn
-		} else if opts.strict {
-			onError = ErrorExit
+	hashFunc := hashFile
+	if opts.pieceSizeStr != "" {
+		hashFunc = hashPieces
+	} else if opts.fsverity {
+		hashFunc = hashFSVerity
+	} else if opts.dmverity {
+		hashFunc = hashDMVerity
+	} else if opts.cdc != nil {
+		hashFunc = hashChunks
+	} else if opts.nar {
+		hashFunc = hashNAR
+	} else if opts.etag {
+		hashFunc = hashETag
+	} else if opts.archive {
+		hashFunc = hashArchive
+	} else if opts.dirhash {
+		if opts.check != "\x00" {
+			hashFunc = hashGoSumEntry
+		} else {
+			hashFunc = hashGoModule
 		}
+	}
+	if opts.checkPerDir != "" {
+		hashFunc = hashFileOrPiece
+		lines = inputFromPerDir(flag.Args(), opts.checkPerDir, opts.zero, errorAction())
+	} else if opts.check != "\x00" {
+		if !opts.dirhash && !opts.etag {
+			hashFunc = hashFileOrPiece
+		}
+		onError := errorAction()
 		f := openFileOrStdin(opts.check)
 		defer f.Close()
-		lines = inputFromCheck(f, opts.zero, onError)
+		if strings.HasSuffix(opts.check, ".torrent") {
+			lines = inputFromTorrent(f, opts.check)
+		} else if opts.sri {
+			lines = inputFromSRI(f, opts.check, opts.zero, onError)
+		} else if opts.dirhash {
+			lines = inputFromGoSum(f, onError)
+		} else if opts.etag {
+			lines = inputFromETag(f, opts.zero, onError)
+		} else if opts.cloud != "" {
+			lines = inputFromCloud(f, opts.cloud, opts.zero, onError)
+		} else {
+			lines = inputFromCheck(f, opts.zero, onError)
+		}
 	} else if opts.input != "\x00" {
 		f := openFileOrStdin(opts.input)
 		defer f.Close()
@@ -294,7 +915,25 @@ func main() {
 		lines = inputFromArgs(flag.Args())
 	}
 
+	if opts.duplicates {
+		groups, failed := findDuplicates(lines)
+		printDuplicates(groups, opts.dupScript)
+		if failed > 0 {
+			os.Exit(1)
+		}
+		os.Exit(0)
+	}
+
 	var unreadable atomic.Uint64
+	if opts.baseDir != "" || opts.strip > 0 || opts.allowOutside {
+		lines = resolveLines(lines, opts.baseDir, opts.strip, opts.allowOutside, &unreadable)
+	}
+
+	var listed map[string]bool
+	if opts.checkExtra != "" {
+		lines, listed = recordListed(lines, opts.check)
+	}
+
 	g := new(errgroup.Group)
 	g.SetLimit(runtime.NumCPU())
 
@@ -303,7 +942,7 @@ func main() {
 		defer close(checksums)
 		for line := range lines {
 			g.Go(func() error {
-				if checksum, err := hashFile(line.file, line.checksums); err != nil {
+				if checksum, err := hashFunc(line.file, line.checksums); err != nil {
 					unreadable.Add(1)
 					if !opts.ignore {
 						log.Print(err)
@@ -319,9 +958,42 @@ func main() {
 		}
 	}()
 
-	if opts.check == "\x00" {
+	if opts.fuzzyMatch != "" {
+		known := inputFromFuzzy(openFileOrStdin(opts.fuzzyMatch), opts.zero, errorAction())
+		matched := 0
+		for checksum := range checksums {
+			matched += printFuzzyMatches(checksum, known)
+		}
+		if unreadable.Load() > 0 || matched == 0 {
+			os.Exit(1)
+		}
+		os.Exit(0)
+	}
+
+	if known != nil {
+		printed := 0
+		for checksum := range checksums {
+			if known.contains(checksum) == (opts.match != "") {
+				printChecksums(checksum, opts)
+				printed++
+			}
+		}
+		if unreadable.Load() > 0 || printed == 0 {
+			os.Exit(1)
+		}
+		os.Exit(0)
+	}
+
+	if opts.check == "\x00" && opts.checkPerDir == "" {
+		var stats cdcStats
 		for checksum := range checksums {
 			printChecksums(checksum, opts)
+			if opts.cdc != nil {
+				stats.add(checksum)
+			}
+		}
+		if opts.cdc != nil {
+			stats.print(os.Stderr)
 		}
 		os.Exit(0)
 	}
@@ -330,11 +1002,25 @@ func main() {
 
 	unmatched := 0
 	for checksum := range checksums {
-		unmatched += printCheckResults(checksum)
+		if opts.sri {
+			unmatched += printSRIResults(checksum)
+		} else {
+			unmatched += printCheckResults(checksum)
+		}
+	}
+
+	var extra []string
+	if opts.checkExtra != "" {
+		extra = findExtra(opts.checkExtra, listed)
+		if !opts.status {
+			for _, file := range extra {
+				fmt.Fprintf(stdout, "%s: NOT LISTED\n", escapeFilename(file))
+			}
+		}
 	}
 
 	unreadableFiles := unreadable.Load()
-	if opts.check != "\x00" || opts.input != "\x00" {
+	if opts.check != "\x00" || opts.checkPerDir != "" || opts.input != "\x00" {
 		plural := ""
 		if !opts.status && unreadableFiles > 0 {
 			if unreadableFiles > 1 {
@@ -348,8 +1034,15 @@ func main() {
 			}
 			fmt.Fprintf(os.Stderr, "WARNING: %d computed checksum%s did NOT match\n", unmatched, plural)
 		}
+		if !opts.status && len(extra) > 0 {
+			if len(extra) > 1 {
+				plural = "s"
+			}
+			fmt.Fprintf(os.Stderr, "WARNING: %d file%s not listed\n", len(extra), plural)
+		}
 	}
-	if unreadableFiles > 0 || unmatched > 0 {
+	// Unlisted files only fail with --strict
+	if unreadableFiles > 0 || unmatched > 0 || opts.strict && len(extra) > 0 {
 		os.Exit(1)
 	}
 }
@@ -3,6 +3,7 @@ package main
 import (
 	"crypto"
 	"log"
+	"slices"
 	"strings"
 	"testing"
 	"text/template"
@@ -61,3 +62,72 @@ func Test_getOutput(t *testing.T) {
 	}
 
 }
+
+func Test_selectAll(t *testing.T) {
+	saved := algorithms
+	defer func() { algorithms = saved }()
+	algorithms = make(map[crypto.Hash]*Algorithm)
+	for _, h := range hashes {
+		algorithms[h] = &Algorithm{check: h == crypto.SHA1}
+	}
+
+	selectAll()
+
+	var selected []crypto.Hash
+	for _, h := range hashes {
+		if algorithms[h].check {
+			selected = append(selected, h)
+		}
+	}
+	if !slices.Contains(selected, crypto.SHA256) || slices.Contains(selected, crypto.SHA1) {
+		t.Errorf("selectAll() got %v", selected)
+	}
+	// Regression: xhash -a -c failed with "SSDEEP can't be checked for equality"
+	for _, h := range selected {
+		if slices.Contains(specialHashes, h) || slices.Contains(fuzzyHashes, h) {
+			t.Errorf("selectAll() selected %v", h)
+		}
+	}
+}
+
+func Test_printCheckResults(t *testing.T) {
+	oldStdout := stdout
+	defer func() { stdout = oldStdout }()
+	var output strings.Builder
+	stdout = &output
+
+	results := &Checksums{
+		file: "file",
+		checksums: []*Checksum{
+			{hash: crypto.MD5, sum: []byte{1}, csum: []byte{1}},
+			{hash: crypto.SHA1, sum: []byte{1}, csum: []byte{2}},
+		},
+	}
+	if unmatched := printCheckResults(results); unmatched != 1 {
+		t.Errorf("printCheckResults() got %d unmatched; want 1", unmatched)
+	}
+	if want := "file: OK\nfile: FAILED\n"; output.String() != want {
+		t.Errorf("printCheckResults() wrote %q; want %q", output.String(), want)
+	}
+}
+
+func Test_specialMode(t *testing.T) {
+	oldOpts := opts
+	defer func() { opts = oldOpts }()
+
+	opts = Options{update: "SUMS"}
+	if mode := specialMode("--update"); mode != "" {
+		t.Errorf("specialMode() got %q; want none", mode)
+	}
+	opts.sri = true
+	if mode := specialMode("--update"); mode != "--sri" {
+		t.Errorf("specialMode() got %q; want --sri", mode)
+	}
+	if mode := specialMode(slices.Concat(outputFormats, []string{"--update"})...); mode != "" {
+		t.Errorf("specialMode() got %q; want none", mode)
+	}
+	opts.decompress = true
+	if mode := specialMode("--update", "--sri"); mode != "--decompress" {
+		t.Errorf("specialMode() got %q; want --decompress", mode)
+	}
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"crypto"
+	"io"
+	"log"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// Hash set used by the --match & --no-match options
+type hashSet map[crypto.Hash]map[string]bool
+
+// Bare digests one per line
+var digestRegex = regexp.MustCompile(`^[0-9a-zA-Z/+_-]{16,}={0,2}$`)
+
+// Read hashes in any format understood by -c or bare digests one per line
+func inputFromHashSet(f io.ReadCloser, zeroTerminated bool, onError ErrorAction) hashSet {
+	defer f.Close()
+
+	set := make(hashSet)
+	scanner, err := getScanner(f, zeroTerminated)
+	if err != nil {
+		log.Fatal(err)
+	}
+	var lineno uint64
+	for scanner.Scan() {
+		lineno++
+		line := scanner.Text()
+		var checksums []*Checksum
+		if digest := strings.TrimSpace(line); digestRegex.MatchString(digest) {
+			if checksum, err := parseDigest("", digest); err == nil {
+				checksums = []*Checksum{checksum}
+			}
+		} else if input, err := parseLine(line, zeroTerminated); err == nil {
+			checksums = input.checksums
+		}
+		// Lines in no known format have an empty digest
+		if checksums == nil || len(checksums[0].csum) == 0 {
+			switch onError {
+			case ErrorWarn:
+				log.Printf("invalid digest at line %d", lineno)
+			case ErrorExit:
+				log.Fatalf("invalid digest at line %d", lineno)
+			}
+			continue
+		}
+		for _, checksum := range checksums {
+			if set[checksum.hash] == nil {
+				set[checksum.hash] = make(map[string]bool)
+			}
+			set[checksum.hash][string(checksum.csum)] = true
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		log.Fatal(err)
+	}
+	return set
+}
+
+// Algorithms in the set, in the order used for output
+func (set hashSet) hashes() (list []crypto.Hash) {
+	for _, h := range hashes {
+		if set[h] != nil {
+			list = append(list, h)
+		}
+	}
+	return list
+}
+
+// Whether any checksum of results is in the set
+func (set hashSet) contains(results *Checksums) bool {
+	return slices.ContainsFunc(results.checksums, func(checksum *Checksum) bool {
+		return set[checksum.hash][string(checksum.sum)]
+	})
+}
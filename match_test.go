@@ -0,0 +1,37 @@
+package main
+
+import (
+	"crypto"
+	"encoding/hex"
+	"io"
+	"strings"
+	"testing"
+)
+
+func Test_inputFromHashSet(t *testing.T) {
+	oldChosen := chosen
+	defer func() { chosen = oldChosen }()
+	chosen = nil
+
+	md5abc := "900150983cd24fb0d6963f7d28e17f72"
+	sha256abc := "ba7816bf8f01cfea414140de5dae2223b00361a396177a9cb410ff61f20015ad"
+	input := strings.Join([]string{
+		"SHA256 (abc) = " + sha256abc,
+		"  " + md5abc + "  ",
+		"garbage",
+		"",
+	}, "\n")
+	set := inputFromHashSet(io.NopCloser(strings.NewReader(input)), false, ErrorIgnore)
+
+	if got := set.hashes(); len(got) != 2 || got[0] != crypto.MD5 || got[1] != crypto.SHA256 {
+		t.Errorf("hashes() got %v; want [MD5 SHA256]", got)
+	}
+
+	sum, _ := hex.DecodeString(md5abc)
+	if !set.contains(&Checksums{checksums: []*Checksum{{hash: crypto.SHA1, sum: sum}, {hash: crypto.MD5, sum: sum}}}) {
+		t.Errorf("contains() should find the MD5 digest")
+	}
+	if set.contains(&Checksums{checksums: []*Checksum{{hash: crypto.SHA256, sum: sum}}}) {
+		t.Errorf("contains() should not find the MD5 digest as SHA256")
+	}
+}
@@ -0,0 +1,162 @@
+package main
+
+import (
+	"crypto"
+	"encoding/base32"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"math/big"
+	"slices"
+	"strings"
+)
+
+// Codes from https://github.com/multiformats/multicodec/blob/master/table.csv
+var hash2multicodec = map[crypto.Hash]uint64{
+	crypto.BLAKE2b_256: 0xb220,
+	crypto.BLAKE2b_512: 0xb240,
+	crypto.BLAKE2s_256: 0xb260,
+	BLAKE3:             0x1e,
+	crypto.MD4:         0xd4,
+	crypto.MD5:         0xd5,
+	crypto.SHA1:        0x11,
+	crypto.SHA256:      0x12,
+	crypto.SHA384:      0x20,
+	crypto.SHA512:      0x13,
+	crypto.SHA512_256:  0x1015,
+	crypto.SHA3_256:    0x16,
+	crypto.SHA3_512:    0x14,
+}
+
+// Multicodec codes used by CIDs
+const (
+	cidVersion1 = 0x01
+	cidRaw      = 0x55
+)
+
+// IPFS splits files into blocks of this size by default
+const ipfsBlockSize = 256 * 1024
+
+const base58Alphabet = "123456789ABCDEFGHJKLMNPQRSTUVWXYZabcdefghijkmnopqrstuvwxyz"
+
+var (
+	base32Lower = base32.NewEncoding("abcdefghijklmnopqrstuvwxyz234567").WithPadding(base32.NoPadding)
+	base32Upper = base32.StdEncoding.WithPadding(base32.NoPadding)
+)
+
+// Multibase encodings from https://github.com/multiformats/multibase
+var multibases = map[string]struct {
+	prefix byte
+	encode func([]byte) string
+	decode func(string) ([]byte, error)
+}{
+	"base16":      {'f', hex.EncodeToString, hex.DecodeString},
+	"base32":      {'b', base32Lower.EncodeToString, base32Lower.DecodeString},
+	"base32upper": {'B', base32Upper.EncodeToString, base32Upper.DecodeString},
+	"base58btc":   {'z', base58Encode, base58Decode},
+	"base64":      {'m', base64.RawStdEncoding.EncodeToString, base64.RawStdEncoding.DecodeString},
+	"base64url":   {'u', base64.RawURLEncoding.EncodeToString, base64.RawURLEncoding.DecodeString},
+}
+
+func base58Encode(data []byte) string {
+	n := new(big.Int).SetBytes(data)
+	radix := big.NewInt(58)
+	mod := new(big.Int)
+	var out []byte
+	for n.Sign() > 0 {
+		n.DivMod(n, radix, mod)
+		out = append(out, base58Alphabet[mod.Int64()])
+	}
+	// Leading zero bytes are encoded as the first character
+	for _, b := range data {
+		if b != 0 {
+			break
+		}
+		out = append(out, base58Alphabet[0])
+	}
+	slices.Reverse(out)
+	return string(out)
+}
+
+func base58Decode(str string) ([]byte, error) {
+	n := new(big.Int)
+	radix := big.NewInt(58)
+	for _, c := range []byte(str) {
+		i := strings.IndexByte(base58Alphabet, c)
+		if i == -1 {
+			return nil, fmt.Errorf("invalid base58 character %q", c)
+		}
+		n.Mul(n, radix)
+		n.Add(n, big.NewInt(int64(i)))
+	}
+	zeroes := len(str) - len(strings.TrimLeft(str, base58Alphabet[:1]))
+	return append(make([]byte, zeroes), n.Bytes()...), nil
+}
+
+// Encode a digest as <varint code><varint length><digest>
+func multihash(h crypto.Hash, sum []byte) []byte {
+	buf := binary.AppendUvarint(nil, hash2multicodec[h])
+	buf = binary.AppendUvarint(buf, uint64(len(sum)))
+	return append(buf, sum...)
+}
+
+// Encode a digest as a CIDv1 with the raw codec
+func cidRawV1(h crypto.Hash, sum []byte) []byte {
+	buf := binary.AppendUvarint(nil, cidVersion1)
+	buf = binary.AppendUvarint(buf, cidRaw)
+	return append(buf, multihash(h, sum)...)
+}
+
+func multibaseEncode(encoding string, data []byte) string {
+	mb := multibases[encoding]
+	return string(mb.prefix) + mb.encode(data)
+}
+
+var errNotMultihash = errors.New("not a multihash")
+
+// Decode a multihash in multibase, or a CIDv0 or CIDv1 with the raw codec
+func parseMultihash(str string) (crypto.Hash, []byte, error) {
+	var data []byte
+	var err error
+	if len(str) == 46 && strings.HasPrefix(str, "Qm") {
+		// CIDv0 is a bare base58btc SHA-256 multihash
+		data, err = base58Decode(str)
+	} else if len(str) > 1 {
+		err = errNotMultihash
+		for _, mb := range multibases {
+			if str[0] == mb.prefix {
+				data, err = mb.decode(str[1:])
+				break
+			}
+		}
+	} else {
+		err = errNotMultihash
+	}
+	if err != nil {
+		return 0, nil, errNotMultihash
+	}
+
+	// Strip the CIDv1 header
+	if version, n := binary.Uvarint(data); n > 0 && version == cidVersion1 {
+		if codec, m := binary.Uvarint(data[n:]); m > 0 && codec == cidRaw {
+			data = data[n+m:]
+		}
+	}
+
+	code, n := binary.Uvarint(data)
+	if n <= 0 {
+		return 0, nil, errNotMultihash
+	}
+	length, m := binary.Uvarint(data[n:])
+	if m <= 0 || length != uint64(len(data[n+m:])) {
+		return 0, nil, errNotMultihash
+	}
+	for h, c := range hash2multicodec {
+		if c == code && (h == BLAKE3 || h.Size() == int(length)) {
+			return h, data[n+m:], nil
+		}
+	}
+	return 0, nil, errNotMultihash
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"bytes"
+	"crypto"
+	"encoding/hex"
+	"testing"
+)
+
+// SHA256("hello world")
+var helloWorldSha256, _ = hex.DecodeString("b94d27b9934d3e08a52e52d7da7dabfac484efe37a5380ee9088f7ace2efcde9")
+
+func Test_base58(t *testing.T) {
+	xwant := map[string]string{
+		"":             "",
+		"\x00\x00abc":  "11ZiCa",
+		"hello world":  "StV1DL6CwTryKyV",
+		"\x00\x01\x02": "15T",
+	}
+	for str, want := range xwant {
+		got := base58Encode([]byte(str))
+		if got != want {
+			t.Errorf("base58Encode(%q) got %q; want %q", str, got, want)
+		}
+		data, err := base58Decode(got)
+		if err != nil || string(data) != str {
+			t.Errorf("base58Decode(%q) got %q; want %q", got, data, str)
+		}
+	}
+}
+
+func Test_multihash(t *testing.T) {
+	want := "zQmaozNR7DZHQK1ZcU9p7QdrshMvXqWK6gpu5rmrkPdT3L4"
+	got := multibaseEncode("base58btc", multihash(crypto.SHA256, helloWorldSha256))
+	if got != want {
+		t.Errorf("multihash() got %q; want %q", got, want)
+	}
+
+	// ipfs add --cid-version=1 --raw-leaves
+	want = "bafkreifzjut3te2nhyekklss27nh3k72ysco7y32koao5eei66wof36n5e"
+	got = multibaseEncode("base32", cidRawV1(crypto.SHA256, helloWorldSha256))
+	if got != want {
+		t.Errorf("cidRawV1() got %q; want %q", got, want)
+	}
+}
+
+func Test_parseMultihash(t *testing.T) {
+	for _, str := range []string{
+		"zQmaozNR7DZHQK1ZcU9p7QdrshMvXqWK6gpu5rmrkPdT3L4",
+		"QmaozNR7DZHQK1ZcU9p7QdrshMvXqWK6gpu5rmrkPdT3L4",
+		"f1220b94d27b9934d3e08a52e52d7da7dabfac484efe37a5380ee9088f7ace2efcde9",
+		"uEiC5TSe5k00-CKUuUtfafav6xITv43pTgO6QiPes4u_N6Q",
+		"bafkreifzjut3te2nhyekklss27nh3k72ysco7y32koao5eei66wof36n5e",
+	} {
+		h, sum, err := parseMultihash(str)
+		if err != nil || h != crypto.SHA256 || !bytes.Equal(sum, helloWorldSha256) {
+			t.Errorf("parseMultihash(%q) got %v %x %v", str, h, sum, err)
+		}
+	}
+
+	// Plain digests must not be mistaken for multihashes
+	for _, str := range []string{
+		"b94d27b9934d3e08a52e52d7da7dabfac484efe37a5380ee9088f7ace2efcde9",
+		"fab8488def7282a75f223a062ec37acc5e35177d0645a9aaf0dc6ca27ae18dbf",
+		"44301b466258398bfee1c974a4a40831",
+	} {
+		if _, _, err := parseMultihash(str); err == nil {
+			t.Errorf("parseMultihash(%q) should fail", str)
+		}
+	}
+}
@@ -0,0 +1,170 @@
+package main
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+// Alphabet used by Nix that omits e, o, t & u
+const nix32Alphabet = "0123456789abcdfghijklmnpqrsvwxyz"
+
+// Nix base32 encodes from the last bit and doesn't pad
+func nix32Encode(data []byte) string {
+	n := (len(data)*8 + 4) / 5
+	out := make([]byte, n)
+	for i := range n {
+		b := (n - 1 - i) * 5
+		j, k := b/8, b%8
+		c := data[j] >> k
+		if j+1 < len(data) {
+			c |= data[j+1] << (8 - k)
+		}
+		out[i] = nix32Alphabet[c&0x1f]
+	}
+	return string(out)
+}
+
+// Writer for the Nix ARchive format
+type narWriter struct {
+	w   io.Writer
+	err error
+}
+
+// Strings are prefixed by their 64-bit little endian length & padded to 8 bytes
+func (nar *narWriter) str(s string) {
+	if nar.err != nil {
+		return
+	}
+	var buf [8]byte
+	binary.LittleEndian.PutUint64(buf[:], uint64(len(s)))
+	if _, nar.err = nar.w.Write(buf[:]); nar.err != nil {
+		return
+	}
+	if _, nar.err = io.WriteString(nar.w, s); nar.err != nil {
+		return
+	}
+	if pad := (8 - len(s)%8) % 8; pad > 0 {
+		_, nar.err = nar.w.Write(make([]byte, pad))
+	}
+}
+
+func (nar *narWriter) strs(strs ...string) {
+	for _, s := range strs {
+		nar.str(s)
+	}
+}
+
+func (nar *narWriter) regular(path string, info fs.FileInfo) {
+	if nar.err != nil {
+		return
+	}
+	nar.strs("(", "type", "regular")
+	if info.Mode()&0o100 != 0 {
+		nar.strs("executable", "")
+	}
+	nar.str("contents")
+	f, err := os.Open(path)
+	if err != nil {
+		nar.err = err
+		return
+	}
+	defer f.Close()
+	var buf [8]byte
+	binary.LittleEndian.PutUint64(buf[:], uint64(info.Size()))
+	if _, nar.err = nar.w.Write(buf[:]); nar.err != nil {
+		return
+	}
+	n, err := io.Copy(nar.w, f)
+	if err != nil {
+		nar.err = err
+		return
+	}
+	if n != info.Size() {
+		nar.err = fmt.Errorf("%s: size changed while reading", path)
+		return
+	}
+	if pad := (8 - n%8) % 8; pad > 0 {
+		_, nar.err = nar.w.Write(make([]byte, pad))
+	}
+	nar.str(")")
+}
+
+// Serialize a path walking directories in the lexical order Nix uses
+func (nar *narWriter) dump(root string) error {
+	nar.str("nix-archive-1")
+
+	var open []string
+	err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		// Close the directories we left
+		for len(open) > 0 && filepath.Dir(path) != open[len(open)-1] {
+			open = open[:len(open)-1]
+			nar.strs(")", ")")
+		}
+		if path != root {
+			nar.strs("entry", "(", "name", d.Name(), "node")
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		switch {
+		case d.IsDir():
+			nar.strs("(", "type", "directory")
+			open = append(open, path)
+			return nar.err
+		case info.Mode()&fs.ModeSymlink != 0:
+			target, err := os.Readlink(path)
+			if err != nil {
+				return err
+			}
+			nar.strs("(", "type", "symlink", "target", target, ")")
+		case info.Mode().IsRegular():
+			nar.regular(path, info)
+		default:
+			return fmt.Errorf("%s: unsupported file type", path)
+		}
+		if path != root {
+			nar.str(")")
+		}
+		return nar.err
+	})
+	if err != nil {
+		return err
+	}
+	for range open {
+		nar.str(")")
+	}
+	// Every directory but the root is inside an entry
+	for range max(len(open)-1, 0) {
+		nar.str(")")
+	}
+	return nar.err
+}
+
+// Used by the --nar option
+func hashNAR(file string, checksums []*Checksum) (*Checksums, error) {
+	if _, err := os.Lstat(file); err != nil {
+		return nil, err
+	}
+	pr, pw := io.Pipe()
+	go func() {
+		nar := &narWriter{w: pw}
+		pw.CloseWithError(nar.dump(filepath.Clean(file)))
+	}()
+	checksums, size := hashF(pr, checksums)
+	if checksums == nil {
+		return nil, fmt.Errorf("%s: error hashing NAR", file)
+	}
+	return &Checksums{
+		file:      file,
+		size:      size,
+		checksums: checksums,
+	}, nil
+}
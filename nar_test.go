@@ -0,0 +1,64 @@
+package main
+
+import (
+	"crypto"
+	"crypto/sha256"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func Test_nix32Encode(t *testing.T) {
+	sum := sha256.Sum256(nil)
+	want := "0mdqa9w1p6cmli6976v4wi0sw9r4p5prkj7lzfd1877wk11c9c73"
+	if got := nix32Encode(sum[:]); got != want {
+		t.Errorf("nix32Encode() got %s; want %s", got, want)
+	}
+	sum2, _ := hex.DecodeString("00")
+	if got := nix32Encode(sum2); got != "00" {
+		t.Errorf("nix32Encode() got %s; want %s", got, "00")
+	}
+}
+
+func Test_hashNAR(t *testing.T) {
+	dir := t.TempDir()
+	root := filepath.Join(dir, "nt")
+	for file, data := range map[string]string{
+		"d/a":   "hello\n",
+		"b":     "x",
+		"d/e/f": "12345678",
+	} {
+		file = filepath.Join(root, file)
+		if err := os.MkdirAll(filepath.Dir(file), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(file, []byte(data), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.Chmod(filepath.Join(root, "b"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink("b", filepath.Join(root, "lnk")); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Mkdir(filepath.Join(root, "empty"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+
+	xwant := map[string]string{
+		"nt":     "0g2i505zilcsa22fw4rfm259gdsghycq1fkhj7089azz307c2i7r",
+		"nt/b":   "1qf3k50cfba7m69yyp7n3dj4g3ckiyyaq16qw6nyh4vrpn97nyzh",
+		"nt/lnk": "17df35vqmbbgjc6f486vvi3ydd1d7xz68sj9kybs3fd3z2wr9b9c",
+	}
+	for file, want := range xwant {
+		got, err := hashNAR(filepath.Join(dir, file), []*Checksum{{hash: crypto.SHA256}})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if sum := nix32Encode(got.checksums[0].sum); sum != want {
+			t.Errorf("hashNAR(%q) got %s; want %s", file, sum, want)
+		}
+	}
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"log"
+	"maps"
+	"os"
+	"path/filepath"
+	"runtime"
+	"slices"
+
+	"golang.org/x/sync/errgroup"
+)
+
+// Write a checksum file named name in each directory under dirs for the files in it, with relative paths
+func writePerDir(dirs []string, name string) (failed int) {
+	byDir := make(map[string][]string)
+	for line := range inputFromDir(dirs, opts.followSymlinks) {
+		if filepath.Base(line.file) != name {
+			dir := filepath.Dir(line.file)
+			byDir[dir] = append(byDir[dir], line.file)
+		}
+	}
+
+	for _, dir := range slices.Sorted(maps.Keys(byDir)) {
+		files := byDir[dir]
+		results := make([]*Checksums, len(files))
+		g := new(errgroup.Group)
+		g.SetLimit(runtime.NumCPU())
+		for i, file := range files {
+			g.Go(func() error {
+				if checksums, err := hashFile(file, nil); err != nil {
+					log.Print(err)
+				} else {
+					checksums.file = filepath.Base(file)
+					results[i] = checksums
+				}
+				return nil
+			})
+		}
+		if err := g.Wait(); err != nil {
+			log.Fatal(err)
+		}
+
+		err := writeChecksums(filepath.Join(dir, name), 0o644, func() {
+			for _, checksums := range results {
+				if checksums == nil {
+					failed++
+				} else {
+					printChecksums(checksums, opts)
+				}
+			}
+		})
+		if err != nil {
+			log.Print(err)
+			failed++
+		}
+	}
+	return failed
+}
+
+// Used by the --check-per-dir option to read the checksum files named name under dirs, with paths relative to each
+func inputFromPerDir(dirs []string, name string, zeroTerminated bool, onError ErrorAction) <-chan *Checksums {
+	files := make(chan *Checksums, chanSize)
+
+	go func() {
+		defer close(files)
+		found := false
+		for line := range inputFromDir(dirs, opts.followSymlinks) {
+			if filepath.Base(line.file) != name {
+				continue
+			}
+			found = true
+			f, err := os.Open(line.file)
+			if err != nil {
+				log.Fatal(err)
+			}
+			dir := filepath.Dir(line.file)
+			for entry := range inputFromCheck(f, zeroTerminated, onError) {
+				if !filepath.IsAbs(entry.file) {
+					entry.file = filepath.Join(dir, entry.file)
+				}
+				files <- entry
+			}
+		}
+		if !found {
+			log.Fatalf("No %s found", name)
+		}
+	}()
+
+	return files
+}
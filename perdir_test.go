@@ -0,0 +1,58 @@
+package main
+
+import (
+	"crypto"
+	"os"
+	"path/filepath"
+	"testing"
+	"text/template"
+)
+
+func Test_writePerDir(t *testing.T) {
+	oldChosen, oldFormat := chosen, format
+	defer func() { chosen, format = oldChosen, oldFormat }()
+	chosen = []crypto.Hash{crypto.MD5}
+	format = template.Must(template.New("format").Parse(gnuFormat))
+
+	dir := t.TempDir()
+	for _, name := range []string{"a", filepath.Join("sub", "b")} {
+		file := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(file), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(file, []byte("abc"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if failed := writePerDir([]string{dir}, "MD5SUMS"); failed != 0 {
+		t.Fatalf("writePerDir() failed for %d files", failed)
+	}
+	// Written again without listing the checksum files themselves
+	if failed := writePerDir([]string{dir}, "MD5SUMS"); failed != 0 {
+		t.Fatalf("writePerDir() failed for %d files", failed)
+	}
+	for _, name := range []string{"a", "b"} {
+		subdir := dir
+		if name == "b" {
+			subdir = filepath.Join(dir, "sub")
+		}
+		got, err := os.ReadFile(filepath.Join(subdir, "MD5SUMS"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if want := "900150983cd24fb0d6963f7d28e17f72  " + name + "\n"; string(got) != want {
+			t.Errorf("writePerDir() got %q; want %q", got, want)
+		}
+	}
+
+	chosen = nil
+	var got []string
+	for entry := range inputFromPerDir([]string{dir}, "MD5SUMS", false, ErrorExit) {
+		got = append(got, entry.file)
+	}
+	want := []string{filepath.Join(dir, "a"), filepath.Join(dir, "sub", "b")}
+	if len(got) != len(want) || got[0] != want[0] || got[1] != want[1] {
+		t.Errorf("inputFromPerDir() got %v; want %v", got, want)
+	}
+}
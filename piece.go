@@ -0,0 +1,122 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"regexp"
+	"strconv"
+)
+
+// Format used by hashdeep -p with inclusive ranges
+var pieceRegex = regexp.MustCompile(`(?s)^(.*) offset ([0-9]+)-([0-9]+)$`)
+
+func pieceName(file string, start, end Size) string {
+	return fmt.Sprintf("%s offset %d-%d", file, start, end)
+}
+
+// Used by the --piece-size option
+func hashPieces(file string, _ []*Checksum) (*Checksums, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+	if info.IsDir() {
+		return nil, fmt.Errorf("%s is a directory", file)
+	}
+
+	// Empty files have no pieces
+	if info.Size() == 0 {
+		checksums, _ := hashF(f, nil)
+		return &Checksums{file: file, checksums: checksums}, nil
+	}
+
+	results := &Checksums{file: file, size: info.Size()}
+	for start := Size(0); start < info.Size(); start += opts.pieceSize {
+		checksums, n := hashF(io.NopCloser(io.LimitReader(f, opts.pieceSize)), nil)
+		if checksums == nil {
+			return nil, fmt.Errorf("%s: error hashing piece at offset %d", file, start)
+		}
+		if n == 0 {
+			break
+		}
+		results.pieces = append(results.pieces, &Checksums{
+			file:      pieceName(file, start, start+n-1),
+			size:      n,
+			checksums: checksums,
+			offset:    start,
+		})
+	}
+	return results, nil
+}
+
+// Hash the range of a file in a line written by --piece-size or an archive member, unless a file with that name exists
+func hashFileOrPiece(file string, checksums []*Checksum) (*Checksums, error) {
+	if archive, member := splitMember(file); archive != "" {
+		return hashMember(archive, member, checksums)
+	}
+	match := pieceRegex.FindStringSubmatch(file)
+	if match == nil {
+		return hashFile(file, checksums)
+	}
+	if _, err := os.Stat(file); err == nil {
+		return hashFile(file, checksums)
+	}
+	start, err1 := strconv.ParseInt(match[2], 10, 64)
+	end, err2 := strconv.ParseInt(match[3], 10, 64)
+	if err1 != nil || err2 != nil || end < start {
+		return nil, fmt.Errorf("%s: invalid range", file)
+	}
+	return hashRange(match[1], start, end-start+1, checksums)
+}
+
+// Hash length bytes from offset, or up to the end if length is negative, of a file or block device
+func hashRange(file string, offset, length Size, checksums []*Checksum) (*Checksums, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+	if mode := info.Mode(); !mode.IsRegular() && (mode&fs.ModeDevice == 0 || mode&fs.ModeCharDevice != 0) {
+		return nil, fmt.Errorf("%s is not a regular file or block device", file)
+	}
+	size, err := fileSize(f)
+	if err != nil {
+		return nil, err
+	}
+	if length < 0 {
+		length = size - offset
+	}
+	if length <= 0 || offset+length > size {
+		return nil, fmt.Errorf("%s: file is too short", file)
+	}
+
+	if _, err := f.Seek(offset, io.SeekStart); err != nil {
+		return nil, err
+	}
+	checksums, n := hashF(io.NopCloser(io.LimitReader(f, length)), checksums)
+	if checksums == nil {
+		return nil, fmt.Errorf("%s: error hashing range", file)
+	}
+	if n != length {
+		return nil, fmt.Errorf("%s: file is too short", file)
+	}
+	return &Checksums{
+		file:      pieceName(file, offset, offset+n-1),
+		size:      n,
+		checksums: checksums,
+		offset:    offset,
+	}, nil
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"crypto"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func Test_hashPieces(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "file")
+	if err := os.WriteFile(file, []byte("abcdefg"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	oldChosen, oldPieceSize := chosen, opts.pieceSize
+	defer func() { chosen, opts.pieceSize = oldChosen, oldPieceSize }()
+	chosen = []crypto.Hash{crypto.MD5}
+	opts.pieceSize = 3
+
+	got, err := hashPieces(file, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// MD5 of "abc", "def" & "g"
+	xwant := []struct{ file, sum string }{
+		{file + " offset 0-2", "900150983cd24fb0d6963f7d28e17f72"},
+		{file + " offset 3-5", "4ed9407630eb1000c0f6b63842defa7d"},
+		{file + " offset 6-6", "b2f5ff47436671b6e533d8dc3614845d"},
+	}
+	if len(got.pieces) != len(xwant) {
+		t.Fatalf("hashPieces() got %d pieces; want %d", len(got.pieces), len(xwant))
+	}
+	for i, want := range xwant {
+		piece := got.pieces[i]
+		if piece.file != want.file || hex.EncodeToString(piece.checksums[0].sum) != want.sum {
+			t.Errorf("hashPieces() got %s %x; want %s %s", piece.file, piece.checksums[0].sum, want.file, want.sum)
+		}
+
+		// Check that each range is hashed again
+		checksums := []*Checksum{{hash: crypto.MD5, csum: piece.checksums[0].sum}}
+		results, err := hashFileOrPiece(piece.file, checksums)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(results.checksums[0].sum) != string(results.checksums[0].csum) {
+			t.Errorf("hashFileOrPiece(%q) got %x; want %x", piece.file, results.checksums[0].sum, results.checksums[0].csum)
+		}
+	}
+
+	if _, err := hashFileOrPiece(file+" offset 5-9", []*Checksum{{hash: crypto.MD5}}); err == nil {
+		t.Errorf("hashFileOrPiece() should fail past the end of file")
+	}
+}
+
+func Test_hashRange(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "file")
+	if err := os.WriteFile(file, []byte("xxabcdefg"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		offset, length Size
+		file, sum      string
+	}{
+		{2, 3, file + " offset 2-4", "900150983cd24fb0d6963f7d28e17f72"},
+		{8, -1, file + " offset 8-8", "b2f5ff47436671b6e533d8dc3614845d"},
+	}
+	for _, tt := range tests {
+		got, err := hashRange(file, tt.offset, tt.length, []*Checksum{{hash: crypto.MD5}})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got.file != tt.file || got.offset != tt.offset || hex.EncodeToString(got.checksums[0].sum) != tt.sum {
+			t.Errorf("hashRange(%d, %d) got %s %d %x; want %s %s", tt.offset, tt.length, got.file, got.offset, got.checksums[0].sum, tt.file, tt.sum)
+		}
+	}
+
+	for _, r := range [][2]Size{{9, -1}, {5, 5}} {
+		if _, err := hashRange(file, r[0], r[1], []*Checksum{{hash: crypto.MD5}}); err == nil {
+			t.Errorf("hashRange(%d, %d) should fail past the end of file", r[0], r[1])
+		}
+	}
+	if _, err := hashRange(filepath.Dir(file), 0, 1, []*Checksum{{hash: crypto.MD5}}); err == nil {
+		t.Errorf("hashRange() should fail on directories")
+	}
+}
@@ -0,0 +1,276 @@
+package main
+
+import (
+	"bufio"
+	"crypto"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"html"
+	"io"
+	"log"
+	"path"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// Algorithms allowed by https://www.w3.org/TR/SRI/ in increasing order of strength
+var sriHashes = []crypto.Hash{
+	crypto.SHA256,
+	crypto.SHA384,
+	crypto.SHA512,
+}
+
+var sriRegex = struct {
+	token, bsd, gnu, tag, attr *regexp.Regexp
+}{
+	// hash-algo "-" base64-value [ "?" option-expression ]
+	regexp.MustCompile(`^(sha256|sha384|sha512)-([A-Za-z0-9+/_-]+={0,2})(?:\?.*)?$`),
+	// Format used by our own output with --sri
+	regexp.MustCompile(`(?s)^SRI \((.*)\) = ((?:sha(?:256|384|512)-[A-Za-z0-9+/]+={0,2} ?)+)$`),
+	// Format used by our own output with --sri --gnu
+	regexp.MustCompile(`(?s)^((?:sha(?:256|384|512)-[A-Za-z0-9+/]+={0,2} ?)+) [ \*](.*)$`),
+	regexp.MustCompile(`(?is)<(?:script|link)\b[^>]*>`),
+	regexp.MustCompile(`(?is)\b(src|href|integrity)\s*=\s*(?:"([^"]*)"|'([^']*)')`),
+}
+
+// Names of the algorithms in integrity metadata, not taken from algorithms
+// as only one of them is registered when running as sha256sum, etc
+var sriNames = map[crypto.Hash]string{
+	crypto.SHA256: "sha256",
+	crypto.SHA384: "sha384",
+	crypto.SHA512: "sha512",
+}
+
+func sriName(h crypto.Hash) string {
+	return sriNames[h]
+}
+
+// Return the integrity metadata for the checksums, one token per algorithm
+func sriString(checksums []*Checksum) string {
+	tokens := make([]string, 0, len(checksums))
+	for _, checksum := range checksums {
+		tokens = append(tokens, sriName(checksum.hash)+"-"+base64.StdEncoding.EncodeToString(checksum.sum))
+	}
+	return strings.Join(tokens, " ")
+}
+
+// Parse integrity metadata returning only the tokens with the strongest algorithm, like browsers do
+func parseSRI(integrity string) []*Checksum {
+	var checksums []*Checksum
+	strongest := -1
+	for _, token := range strings.Fields(integrity) {
+		match := sriRegex.token.FindStringSubmatch(token)
+		if match == nil {
+			continue
+		}
+		// Accept the URL-safe alphabet too as browsers do
+		digest := strings.NewReplacer("-", "+", "_", "/").Replace(match[2])
+		sum, err := base64.StdEncoding.DecodeString(digest)
+		if err != nil {
+			continue
+		}
+		index := slices.IndexFunc(sriHashes, func(h crypto.Hash) bool { return sriName(h) == match[1] })
+		if len(sum) != sriHashes[index].Size() || index < strongest {
+			continue
+		}
+		if index > strongest {
+			strongest = index
+			checksums = nil
+		}
+		checksums = append(checksums, &Checksum{hash: sriHashes[index], csum: sum})
+	}
+	return checksums
+}
+
+// Resolve a reference relative to the directory of the file containing it,
+// which is also the root for references starting with a slash
+func sriFile(ref string, dir string) string {
+	if i := strings.IndexAny(ref, "?#"); i != -1 {
+		ref = ref[:i]
+	}
+	if strings.Contains(ref, "://") {
+		ref = path.Base(ref)
+	}
+	ref = strings.TrimPrefix(ref, "file:")
+	return filepath.Join(dir, filepath.FromSlash(ref))
+}
+
+// Used by the package-lock.json format of npm
+type npmLock struct {
+	Packages map[string]struct {
+		Resolved  string `json:"resolved"`
+		Integrity string `json:"integrity"`
+	} `json:"packages"`
+	Dependencies map[string]json.RawMessage `json:"dependencies"`
+}
+
+func sriFromJSON(data []byte, dir string) ([]*Checksums, error) {
+	var lock npmLock
+	if err := json.Unmarshal(data, &lock); err != nil {
+		return nil, err
+	}
+	var inputs []*Checksums
+	for _, pkg := range lock.Packages {
+		if pkg.Integrity == "" || pkg.Resolved == "" {
+			continue
+		}
+		if checksums := parseSRI(pkg.Integrity); checksums != nil {
+			inputs = append(inputs, &Checksums{file: sriFile(pkg.Resolved, dir), checksums: checksums})
+		}
+	}
+	// lockfileVersion 1 nests dependencies
+	var walk func(deps map[string]json.RawMessage) error
+	walk = func(deps map[string]json.RawMessage) error {
+		for _, raw := range deps {
+			var dep struct {
+				Resolved     string                     `json:"resolved"`
+				Integrity    string                     `json:"integrity"`
+				Dependencies map[string]json.RawMessage `json:"dependencies"`
+			}
+			if err := json.Unmarshal(raw, &dep); err != nil {
+				return err
+			}
+			if dep.Integrity != "" && dep.Resolved != "" {
+				if checksums := parseSRI(dep.Integrity); checksums != nil {
+					inputs = append(inputs, &Checksums{file: sriFile(dep.Resolved, dir), checksums: checksums})
+				}
+			}
+			if err := walk(dep.Dependencies); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	if lock.Packages == nil {
+		if err := walk(lock.Dependencies); err != nil {
+			return nil, err
+		}
+	}
+	return inputs, nil
+}
+
+func sriFromHTML(data []byte, dir string) []*Checksums {
+	var inputs []*Checksums
+	for _, tag := range sriRegex.tag.FindAll(data, -1) {
+		var ref, integrity string
+		for _, match := range sriRegex.attr.FindAllSubmatch(tag, -1) {
+			value := html.UnescapeString(string(match[2]) + string(match[3]))
+			if strings.EqualFold(string(match[1]), "integrity") {
+				integrity = value
+			} else {
+				ref = value
+			}
+		}
+		if ref == "" || integrity == "" {
+			continue
+		}
+		if checksums := parseSRI(integrity); checksums != nil {
+			inputs = append(inputs, &Checksums{file: sriFile(ref, dir), checksums: checksums})
+		}
+	}
+	return inputs
+}
+
+func sriFromLines(data []byte, zeroTerminated bool, onError ErrorAction) []*Checksums {
+	var inputs []*Checksums
+	scanner := bufio.NewScanner(strings.NewReader(string(data)))
+	if zeroTerminated {
+		scanner.Split(scanLinesZ)
+	}
+	var lineno uint64
+	for scanner.Scan() {
+		lineno++
+		var checksums []*Checksum
+		var file string
+		if match := sriRegex.bsd.FindStringSubmatch(scanner.Text()); match != nil {
+			file, checksums = match[1], parseSRI(match[2])
+		} else if match = sriRegex.gnu.FindStringSubmatch(scanner.Text()); match != nil {
+			file, checksums = match[2], parseSRI(match[1])
+		}
+		if checksums == nil {
+			switch onError {
+			case ErrorWarn:
+				log.Printf("invalid digest at line %d", lineno)
+			case ErrorExit:
+				log.Fatalf("invalid digest at line %d", lineno)
+			}
+			continue
+		}
+		if !zeroTerminated {
+			file = unescapeFilename(file)
+		}
+		inputs = append(inputs, &Checksums{file: file, checksums: checksums})
+	}
+	return inputs
+}
+
+// Used by the -c option with --sri to read integrity metadata from HTML, package-lock.json or our own output
+func inputFromSRI(f io.ReadCloser, name string, zeroTerminated bool, onError ErrorAction) <-chan *Checksums {
+	files := make(chan *Checksums, chanSize)
+
+	go func() {
+		defer close(files)
+		defer f.Close()
+
+		data, err := io.ReadAll(f)
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		// References are relative to the directory of the file containing them
+		dir := filepath.Dir(name)
+		if name == "" {
+			dir = "."
+		}
+
+		var inputs []*Checksums
+		trimmed := strings.TrimSpace(string(data))
+		switch {
+		case strings.HasPrefix(trimmed, "{"):
+			if inputs, err = sriFromJSON(data, dir); err != nil {
+				log.Fatal(err)
+			}
+		case strings.HasPrefix(trimmed, "<"):
+			inputs = sriFromHTML(data, dir)
+		default:
+			inputs = sriFromLines(data, zeroTerminated, onError)
+		}
+		if len(inputs) == 0 {
+			log.Fatal("No valid integrity metadata found")
+		}
+		for _, input := range inputs {
+			files <- input
+		}
+	}()
+
+	return files
+}
+
+// Browsers accept a resource if any token of the strongest algorithm matches
+func printSRIResults(results *Checksums) (unmatched int) {
+	file := escapeFilename(results.file)
+	ok := slices.ContainsFunc(results.checksums, func(checksum *Checksum) bool {
+		return string(checksum.sum) == string(checksum.csum)
+	})
+	if ok {
+		if !opts.quiet && !opts.status {
+			if opts.verbose {
+				fmt.Fprintf(stdout, "%s: %s OK\n", file, strings.ToUpper(sriName(results.checksums[0].hash)))
+			} else {
+				fmt.Fprintf(stdout, "%s: OK\n", file)
+			}
+		}
+		return 0
+	}
+	if !opts.status {
+		if opts.verbose {
+			fmt.Fprintf(stdout, "%s: %s FAILED with %s\n", file, strings.ToUpper(sriName(results.checksums[0].hash)), sriString(results.checksums[:1]))
+		} else {
+			fmt.Fprintf(stdout, "%s: FAILED\n", file)
+		}
+	}
+	return 1
+}
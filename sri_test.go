@@ -0,0 +1,108 @@
+package main
+
+import (
+	"crypto"
+	"encoding/base64"
+	"reflect"
+	"testing"
+)
+
+// SHA384("alert('Hello, world.');") from https://developer.mozilla.org/en-US/docs/Web/Security/Subresource_Integrity
+const helloSRI = "sha384-H8BRh8j48O9oYatfu5AZzq6A9RINhZO5H16dQZngK7T62em8MUt1FLm52t+eX6xO"
+
+func Test_sriString(t *testing.T) {
+	oldChosen := chosen
+	defer func() { chosen = oldChosen }()
+	chosen = []crypto.Hash{crypto.SHA384}
+
+	got := sriString(hashString("alert('Hello, world.');").checksums)
+	if got != helloSRI {
+		t.Errorf("sriString() got %q; want %q", got, helloSRI)
+	}
+}
+
+func Test_parseSRI(t *testing.T) {
+	sum, _ := base64.StdEncoding.DecodeString("H8BRh8j48O9oYatfu5AZzq6A9RINhZO5H16dQZngK7T62em8MUt1FLm52t+eX6xO")
+	want := []*Checksum{{hash: crypto.SHA384, csum: sum}}
+
+	for _, integrity := range []string{
+		helloSRI,
+		"sha256-qznLcsROx4GACP2dm0UCKCzCG+HiZ1guq6ZZDob/Tng= " + helloSRI,
+		helloSRI + "?foo md5-AAAA sha256-qznLcsROx4GACP2dm0UCKCzCG+HiZ1guq6ZZDob/Tng=",
+	} {
+		got := parseSRI(integrity)
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("parseSRI(%q) got %v; want %v", integrity, got, want)
+		}
+	}
+
+	if got := parseSRI(helloSRI + " " + helloSRI); len(got) != 2 {
+		t.Errorf("parseSRI() got %d tokens; want 2", len(got))
+	}
+	if got := parseSRI("md5-AAAA sha384-AAAA"); got != nil {
+		t.Errorf("parseSRI() got %v; want nil", got)
+	}
+}
+
+func Test_sriFromHTML(t *testing.T) {
+	html := `<link rel="stylesheet" href="css/a.css?v=1" integrity="` + helloSRI + `">
+<script integrity='` + helloSRI + `' src="https://cdn.example.com/js/b.js" crossorigin="anonymous"></script>
+<script src="c.js"></script>
+<script src="/js/d.js" integrity="` + helloSRI + `"></script>`
+
+	var got []string
+	for _, input := range sriFromHTML([]byte(html), "www") {
+		got = append(got, input.file)
+	}
+	want := []string{"www/css/a.css", "www/b.js", "www/js/d.js"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("sriFromHTML() got %v; want %v", got, want)
+	}
+}
+
+func Test_sriFromJSON(t *testing.T) {
+	lock := `{"packages": {"": {}, "node_modules/a": {"resolved": "https://registry.npmjs.org/a/-/a-1.0.0.tgz", "integrity": "` + helloSRI + `"}}}`
+	got, err := sriFromJSON([]byte(lock), "dir")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 1 || got[0].file != "dir/a-1.0.0.tgz" {
+		t.Errorf("sriFromJSON() got %v", got)
+	}
+
+	lock = `{"dependencies": {"a": {"resolved": "file:a.tgz", "integrity": "` + helloSRI + `", "dependencies": {"b": {"resolved": "b.tgz", "integrity": "` + helloSRI + `"}}}}}`
+	got, err = sriFromJSON([]byte(lock), ".")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 2 {
+		t.Errorf("sriFromJSON() got %v", got)
+	}
+}
+
+func Test_sriFromLines(t *testing.T) {
+	lines := "sha256-qznLcsROx4GACP2dm0UCKCzCG+HiZ1guq6ZZDob/Tng= " + helloSRI + "  hello.js\ngarbage\nSRI (a (1).js) = " + helloSRI + "\n"
+	got := sriFromLines([]byte(lines), false, ErrorIgnore)
+	if len(got) != 2 || got[0].file != "hello.js" || got[0].checksums[0].hash != crypto.SHA384 {
+		t.Errorf("sriFromLines() got %v", got)
+	}
+	if len(got) == 2 && (got[1].file != "a (1).js" || len(got[1].checksums) != 1) {
+		t.Errorf("sriFromLines() got %v", got[1])
+	}
+}
+
+func Test_parseSRISumMode(t *testing.T) {
+	// Only one algorithm is registered when running as sha256sum
+	oldAlgorithms := algorithms
+	defer func() { algorithms = oldAlgorithms }()
+	algorithms = map[crypto.Hash]*Algorithm{crypto.SHA256: {name: "SHA256"}}
+
+	got := parseSRI(helloSRI)
+	if len(got) != 1 || got[0].hash != crypto.SHA384 {
+		t.Fatalf("parseSRI() got %v", got)
+	}
+	got[0].sum = got[0].csum
+	if s := sriString(got); s != helloSRI {
+		t.Errorf("sriString() got %q; want %q", s, helloSRI)
+	}
+}
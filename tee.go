@@ -0,0 +1,74 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+)
+
+// Hash stdin, or file if not empty, in a single pass while copying it to the files used by --tee and to stdout with -p
+func hashTee(file string, tees []string, passthrough bool) (*Checksums, error) {
+	f := os.Stdin
+	if file != "" {
+		var err error
+		if f, err = os.Open(file); err != nil {
+			return nil, err
+		}
+		defer f.Close()
+	}
+
+	var writers []io.Writer
+	var files []*os.File
+	for _, tee := range tees {
+		w, err := os.Create(tee)
+		if err != nil {
+			closeAll(files)
+			return nil, err
+		}
+		writers = append(writers, w)
+		files = append(files, w)
+	}
+	if passthrough {
+		writers = append(writers, os.Stdout)
+	}
+
+	// Data is copied as read, before any decompression
+	tee := io.TeeReader(f, io.MultiWriter(writers...))
+	r := io.NopCloser(tee)
+	if opts.decompress {
+		var err error
+		if r, err = decompress(tee); err != nil {
+			closeAll(files)
+			return nil, fmt.Errorf("%s: %v", f.Name(), err)
+		}
+		defer r.Close()
+	}
+
+	checksums, size := hashF(r, nil)
+	if checksums == nil {
+		closeAll(files)
+		return nil, fmt.Errorf("%s: error hashing", f.Name())
+	}
+	// Copy any trailing data not read by the decompressor
+	if _, err := io.Copy(io.Discard, tee); err != nil {
+		closeAll(files)
+		return nil, err
+	}
+	if err := closeAll(files); err != nil {
+		return nil, err
+	}
+	return &Checksums{
+		file:      file,
+		size:      size,
+		checksums: checksums,
+	}, nil
+}
+
+func closeAll(files []*os.File) error {
+	var errs []error
+	for _, f := range files {
+		errs = append(errs, f.Close())
+	}
+	return errors.Join(errs...)
+}
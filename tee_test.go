@@ -0,0 +1,49 @@
+package main
+
+import (
+	"crypto"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func Test_hashTee(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "file")
+	if err := os.WriteFile(file, []byte("abc"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	oldChosen := chosen
+	defer func() { chosen = oldChosen }()
+	chosen = []crypto.Hash{crypto.MD5, crypto.SHA1}
+
+	tees := []string{filepath.Join(dir, "tee1"), filepath.Join(dir, "tee2")}
+	got, err := hashTee(file, tees, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.file != file || got.size != 3 {
+		t.Errorf("hashTee() got %s %d; want %s 3", got.file, got.size, file)
+	}
+	xwant := []string{"900150983cd24fb0d6963f7d28e17f72", "a9993e364706816aba3e25717850c26c9cd0d89d"}
+	for i, want := range xwant {
+		if sum := hex.EncodeToString(got.checksums[i].sum); sum != want {
+			t.Errorf("hashTee() got %s; want %s", sum, want)
+		}
+	}
+	for _, tee := range tees {
+		data, err := os.ReadFile(tee)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(data) != "abc" {
+			t.Errorf("hashTee() copied %q to %s; want %q", data, tee, "abc")
+		}
+	}
+
+	if _, err := hashTee(file, []string{filepath.Join(dir, "missing", "tee")}, false); err == nil {
+		t.Errorf("hashTee() should fail with an invalid destination")
+	}
+}
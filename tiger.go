@@ -0,0 +1,243 @@
+package main
+
+import (
+	"encoding/binary"
+	"hash"
+)
+
+// Tiger hash by Ross Anderson & Eli Biham as used by the Tiger Tree Hash (TTH)
+const (
+	tigerSize      = 24
+	tigerBlockSize = 64
+)
+
+var tigerTable [4 * 256]uint64
+
+// Generate the S-boxes as done by the reference implementation instead of hardcoding 8 KiB
+func init() {
+	var block [tigerBlockSize]byte
+	copy(block[:], "Tiger - A Fast New Hash Function, by Ross Anderson and Eli Biham")
+	for i := range tigerTable {
+		tigerTable[i] = 0x0101010101010101 * uint64(i&0xff)
+	}
+	state := [3]uint64{0x0123456789ABCDEF, 0xFEDCBA9876543210, 0xF096A5B4C3B2E187}
+	abc := 2
+	for range 5 {
+		for i := range 256 {
+			for sb := 0; sb < len(tigerTable); sb += 256 {
+				if abc++; abc == 3 {
+					abc = 0
+					tigerCompress(&state, block[:])
+				}
+				for col := range 8 {
+					shift := 8 * col
+					j := sb + int(state[abc]>>shift&0xff)
+					mask := uint64(0xff) << shift
+					x, y := tigerTable[sb+i]&mask, tigerTable[j]&mask
+					tigerTable[sb+i] = tigerTable[sb+i]&^mask | y
+					tigerTable[j] = tigerTable[j]&^mask | x
+				}
+			}
+		}
+	}
+}
+
+func tigerRound(a, b, c *uint64, x, mul uint64) {
+	*c ^= x
+	t1, t2, t3, t4 := tigerTable[:256], tigerTable[256:512], tigerTable[512:768], tigerTable[768:]
+	*a -= t1[byte(*c)] ^ t2[byte(*c>>16)] ^ t3[byte(*c>>32)] ^ t4[byte(*c>>48)]
+	*b += t4[byte(*c>>8)] ^ t3[byte(*c>>24)] ^ t2[byte(*c>>40)] ^ t1[byte(*c>>56)]
+	*b *= mul
+}
+
+func tigerPass(a, b, c *uint64, x *[8]uint64, mul uint64) {
+	tigerRound(a, b, c, x[0], mul)
+	tigerRound(b, c, a, x[1], mul)
+	tigerRound(c, a, b, x[2], mul)
+	tigerRound(a, b, c, x[3], mul)
+	tigerRound(b, c, a, x[4], mul)
+	tigerRound(c, a, b, x[5], mul)
+	tigerRound(a, b, c, x[6], mul)
+	tigerRound(b, c, a, x[7], mul)
+}
+
+func tigerKeySchedule(x *[8]uint64) {
+	x[0] -= x[7] ^ 0xA5A5A5A5A5A5A5A5
+	x[1] ^= x[0]
+	x[2] += x[1]
+	x[3] -= x[2] ^ (^x[1] << 19)
+	x[4] ^= x[3]
+	x[5] += x[4]
+	x[6] -= x[5] ^ (^x[4] >> 23)
+	x[7] ^= x[6]
+	x[0] += x[7]
+	x[1] -= x[0] ^ (^x[7] << 19)
+	x[2] ^= x[1]
+	x[3] += x[2]
+	x[4] -= x[3] ^ (^x[2] >> 23)
+	x[5] ^= x[4]
+	x[6] += x[5]
+	x[7] -= x[6] ^ 0x0123456789ABCDEF
+}
+
+func tigerCompress(state *[3]uint64, block []byte) {
+	var x [8]uint64
+	for i := range x {
+		x[i] = binary.LittleEndian.Uint64(block[8*i:])
+	}
+	a, b, c := state[0], state[1], state[2]
+	tigerPass(&a, &b, &c, &x, 5)
+	tigerKeySchedule(&x)
+	tigerPass(&c, &a, &b, &x, 7)
+	tigerKeySchedule(&x)
+	tigerPass(&b, &c, &a, &x, 9)
+	state[0] ^= a
+	state[1] = b - state[1]
+	state[2] += c
+}
+
+type tigerHash struct {
+	state [3]uint64
+	block [tigerBlockSize]byte
+	n     int
+	len   uint64
+}
+
+func newTiger() hash.Hash {
+	t := new(tigerHash)
+	t.Reset()
+	return t
+}
+
+func (t *tigerHash) Write(p []byte) (int, error) {
+	written := len(p)
+	t.len += uint64(len(p))
+	for len(p) > 0 {
+		n := copy(t.block[t.n:], p)
+		t.n += n
+		p = p[n:]
+		if t.n == tigerBlockSize {
+			tigerCompress(&t.state, t.block[:])
+			t.n = 0
+		}
+	}
+	return written, nil
+}
+
+// Padded like MD4 but with 0x01 as the first byte
+func (t *tigerHash) Sum(in []byte) []byte {
+	d := *t
+	var pad [tigerBlockSize + 8]byte
+	pad[0] = 0x01
+	n := (tigerBlockSize - 8 - 1 - d.n + tigerBlockSize) % tigerBlockSize
+	binary.LittleEndian.PutUint64(pad[1+n:], d.len<<3)
+	d.Write(pad[:1+n+8])
+	for _, v := range d.state {
+		in = binary.LittleEndian.AppendUint64(in, v)
+	}
+	return in
+}
+
+func (t *tigerHash) Reset() {
+	t.state = [3]uint64{0x0123456789ABCDEF, 0xFEDCBA9876543210, 0xF096A5B4C3B2E187}
+	t.n = 0
+	t.len = 0
+}
+
+func (t *tigerHash) clone() hash.Hash {
+	d := *t
+	return &d
+}
+
+func (t *tigerHash) Size() int      { return tigerSize }
+func (t *tigerHash) BlockSize() int { return tigerBlockSize }
+
+// THEX hashes 1 KiB leaves prefixed with 0x00 & internal nodes prefixed with 0x01,
+// promoting the last node of odd levels
+const tthBlockSize = 1024
+
+type tthHash struct {
+	tiger hash.Hash
+	n     int
+	leaf  bool     // Whether the current leaf was started
+	stack [][]byte // Roots of complete subtrees of decreasing size
+	count uint64   // Number of leaves
+}
+
+func newTTH() hash.Hash {
+	return &tthHash{tiger: newTiger()}
+}
+
+func (t *tthHash) node(left, right []byte) []byte {
+	t.tiger.Reset()
+	t.tiger.Write([]byte{0x01})
+	t.tiger.Write(left)
+	t.tiger.Write(right)
+	return t.tiger.Sum(nil)
+}
+
+func (t *tthHash) startLeaf() {
+	t.tiger.Reset()
+	t.tiger.Write([]byte{0x00})
+	t.leaf = true
+}
+
+func (t *tthHash) endLeaf() {
+	leaf := t.tiger.Sum(nil)
+	t.leaf = false
+	t.n = 0
+	// Merge subtrees of the same size like a binary counter
+	t.count++
+	t.stack = append(t.stack, leaf)
+	for i := t.count; i&1 == 0; i >>= 1 {
+		last := len(t.stack) - 1
+		t.stack = append(t.stack[:last-1], t.node(t.stack[last-1], t.stack[last]))
+	}
+}
+
+func (t *tthHash) Write(p []byte) (int, error) {
+	written := len(p)
+	for len(p) > 0 {
+		if !t.leaf {
+			t.startLeaf()
+		}
+		n := min(len(p), tthBlockSize-t.n)
+		t.tiger.Write(p[:n])
+		t.n += n
+		p = p[n:]
+		if t.n == tthBlockSize {
+			t.endLeaf()
+		}
+	}
+	return written, nil
+}
+
+func (t *tthHash) Sum(in []byte) []byte {
+	d := &tthHash{tiger: newTiger(), count: t.count, stack: make([][]byte, len(t.stack))}
+	copy(d.stack, t.stack)
+	if t.leaf {
+		d.tiger = t.tiger.(*tigerHash).clone()
+		d.leaf, d.n = true, t.n
+		d.endLeaf()
+	} else if t.count == 0 {
+		// The empty file has an empty leaf
+		d.startLeaf()
+		d.endLeaf()
+	}
+	root := d.stack[len(d.stack)-1]
+	for i := len(d.stack) - 2; i >= 0; i-- {
+		root = d.node(d.stack[i], root)
+	}
+	return append(in, root...)
+}
+
+func (t *tthHash) Reset() {
+	t.tiger.Reset()
+	t.n = 0
+	t.leaf = false
+	t.stack = nil
+	t.count = 0
+}
+
+func (t *tthHash) Size() int      { return tigerSize }
+func (t *tthHash) BlockSize() int { return tthBlockSize }
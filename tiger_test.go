@@ -0,0 +1,51 @@
+package main
+
+import (
+	"encoding/hex"
+	"strings"
+	"testing"
+)
+
+func Test_tiger(t *testing.T) {
+	// Test vectors from the Tiger reference implementation
+	xwant := map[string]string{
+		"":                           "3293ac630c13f0245f92bbb1766e16167a4e58492dde73f3",
+		"abc":                        "2aab1484e8c158f2bfb8c5ff41b57a525129131c957b5f93",
+		"Tiger":                      "dd00230799f5009fec6debc838bb6a27df2b9d6f110c7937",
+		strings.Repeat("a", 1000000): "6db0e2729cbead93d715c6a7d36302e9b3cee0d2bc314b41",
+	}
+	for data, want := range xwant {
+		h := newTiger()
+		h.Write([]byte(data))
+		if got := hex.EncodeToString(h.Sum(nil)); got != want {
+			t.Errorf("tiger(%.10q) got %s; want %s", data, got, want)
+		}
+	}
+}
+
+func Test_tth(t *testing.T) {
+	// Test vectors from the THEX specification
+	xwant := map[string]string{
+		"":                        "LWPNACQDBZRYXW3VHJVCJ64QBZNGHOHHHZWCLNQ",
+		"\x00":                    "VK54ZIEEVTWNAUI5D5RDFIL37LX2IQNSTAXFKSA",
+		strings.Repeat("A", 1024): "L66Q4YVNAFWVS23X2HJIRA5ZJ7WXR3F26RSASFA",
+		strings.Repeat("A", 1025): "PZMRYHGY6LTBEH63ZWAHDORHSYTLO4LEFUIKHWY",
+	}
+	for data, want := range xwant {
+		h := newTTH()
+		// Write in pieces to exercise partial leaves
+		for len(data) > 0 {
+			n := min(len(data), 100)
+			h.Write([]byte(data[:n]))
+			data = data[n:]
+		}
+		got := tthEncoding.EncodeToString(h.Sum(nil))
+		if got != want {
+			t.Errorf("tth() got %s; want %s", got, want)
+		}
+		// Sum must not change the state
+		if again := tthEncoding.EncodeToString(h.Sum(nil)); again != got {
+			t.Errorf("tth() got %s after Sum; want %s", again, got)
+		}
+	}
+}
@@ -0,0 +1,214 @@
+package main
+
+import (
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"math"
+	"math/bits"
+	"slices"
+	"strings"
+)
+
+// TLSH by Trend Micro with 128 buckets & a 1 byte checksum as output by tlsh -version 4
+const (
+	tlshWindow     = 5
+	tlshBuckets    = 128
+	tlshCodeSize   = tlshBuckets / 4
+	tlshMinLength  = 50
+	tlshDigestSize = 2 + 2*(3+tlshCodeSize) // "T1" & the hexadecimal header & body
+	tlshNull       = "TNULL"                // Output for data too short or without enough variation
+)
+
+// Pearson hash table
+var tlshTable = [256]byte{
+	1, 87, 49, 12, 176, 178, 102, 166, 121, 193, 6, 84, 249, 230, 44, 163,
+	14, 197, 213, 181, 161, 85, 218, 80, 64, 239, 24, 226, 236, 142, 38, 200,
+	110, 177, 104, 103, 141, 253, 255, 50, 77, 101, 81, 18, 45, 96, 31, 222,
+	25, 107, 190, 70, 86, 237, 240, 34, 72, 242, 20, 214, 244, 227, 149, 235,
+	97, 234, 57, 22, 60, 250, 82, 175, 208, 5, 127, 199, 111, 62, 135, 248,
+	174, 169, 211, 58, 66, 154, 106, 195, 245, 171, 17, 187, 182, 179, 0, 243,
+	132, 56, 148, 75, 128, 133, 158, 100, 130, 126, 91, 13, 153, 246, 216, 219,
+	119, 68, 223, 78, 83, 88, 201, 99, 122, 11, 92, 32, 136, 114, 52, 10,
+	138, 30, 48, 183, 156, 35, 61, 26, 143, 74, 251, 94, 129, 162, 63, 152,
+	170, 7, 115, 167, 241, 206, 3, 150, 55, 59, 151, 220, 90, 53, 23, 131,
+	125, 173, 15, 238, 79, 95, 89, 16, 105, 137, 225, 224, 217, 160, 37, 123,
+	118, 73, 2, 157, 46, 116, 9, 145, 134, 228, 207, 212, 202, 215, 69, 229,
+	27, 188, 67, 124, 168, 252, 42, 4, 29, 108, 21, 247, 19, 205, 39, 203,
+	233, 40, 186, 147, 198, 192, 155, 33, 164, 191, 98, 204, 165, 180, 117, 76,
+	140, 36, 210, 172, 41, 54, 159, 8, 185, 232, 113, 196, 231, 47, 146, 120,
+	51, 65, 28, 144, 254, 221, 93, 189, 194, 139, 112, 43, 71, 109, 184, 209,
+}
+
+// Upper bounds of the logarithmic buckets used to capture the data length
+var tlshTopValues []uint64
+
+func init() {
+	for i := 0; ; i++ {
+		var top float64
+		switch {
+		case i <= 15:
+			top = math.Pow(1.5, float64(i+1))
+		case i <= 21:
+			top = math.Pow(1.3, float64(i+1)+8.72777)
+		default:
+			top = math.Pow(1.1, float64(i+1)+62.5472)
+		}
+		tlshTopValues = append(tlshTopValues, uint64(top))
+		if top > math.MaxUint32 {
+			break
+		}
+	}
+}
+
+func tlshLength(n uint64) byte {
+	i, _ := slices.BinarySearch(tlshTopValues, n)
+	return byte(i)
+}
+
+func tlshMapping(salt, i, j, k byte) byte {
+	return tlshTable[tlshTable[tlshTable[tlshTable[salt]^i]^j]^k]
+}
+
+type tlshHash struct {
+	buckets  [256]uint32
+	window   [tlshWindow]byte
+	checksum byte
+	n        uint64
+}
+
+func newTLSH() hash.Hash {
+	return new(tlshHash)
+}
+
+func (t *tlshHash) Write(p []byte) (int, error) {
+	for _, c := range p {
+		j := t.n % tlshWindow
+		t.window[j] = c
+		if t.n >= tlshWindow-1 {
+			w := func(k uint64) byte { return t.window[(j+tlshWindow-k)%tlshWindow] }
+			w0, w1, w2, w3, w4 := w(0), w(1), w(2), w(3), w(4)
+			t.checksum = tlshMapping(0, w0, w1, t.checksum)
+			t.buckets[tlshMapping(2, w0, w1, w2)]++
+			t.buckets[tlshMapping(3, w0, w1, w3)]++
+			t.buckets[tlshMapping(5, w0, w2, w3)]++
+			t.buckets[tlshMapping(7, w0, w2, w4)]++
+			t.buckets[tlshMapping(11, w0, w1, w4)]++
+			t.buckets[tlshMapping(13, w0, w3, w4)]++
+		}
+		t.n++
+	}
+	return len(p), nil
+}
+
+func swapNibbles(b byte) byte {
+	return bits.RotateLeft8(b, 4)
+}
+
+// The digest is "T1" followed by the checksum, length & quartile ratios with swapped nibbles,
+// and 2 bits per bucket depending on the quartile of its count from the last bucket
+func (t *tlshHash) Sum(in []byte) []byte {
+	if t.n < tlshMinLength {
+		return append(in, tlshNull...)
+	}
+	sorted := slices.Clone(t.buckets[:tlshBuckets])
+	slices.Sort(sorted)
+	q1, q2, q3 := sorted[tlshBuckets/4-1], sorted[tlshBuckets/2-1], sorted[tlshBuckets*3/4-1]
+	// More than half of the buckets must be non-zero
+	if zeroes := slices.IndexFunc(sorted, func(n uint32) bool { return n > 0 }); zeroes == -1 || zeroes >= tlshBuckets/2 {
+		return append(in, tlshNull...)
+	}
+
+	digest := []byte{
+		swapNibbles(t.checksum),
+		swapNibbles(tlshLength(t.n)),
+		byte((q1*100/q3)%16)<<4 | byte((q2*100/q3)%16),
+	}
+	for i := tlshCodeSize - 1; i >= 0; i-- {
+		var code byte
+		for j := 3; j >= 0; j-- {
+			code <<= 2
+			switch n := t.buckets[4*i+j]; {
+			case n > q3:
+				code |= 3
+			case n > q2:
+				code |= 2
+			case n > q1:
+				code |= 1
+			}
+		}
+		digest = append(digest, code)
+	}
+	return append(in, "T1"+strings.ToUpper(hex.EncodeToString(digest))...)
+}
+
+func (t *tlshHash) Reset() {
+	*t = tlshHash{}
+}
+
+func (t *tlshHash) Size() int      { return tlshDigestSize }
+func (t *tlshHash) BlockSize() int { return tlshWindow }
+
+type tlshDigest struct {
+	checksum, length, q1, q2 byte
+	code                     []byte
+}
+
+func parseTLSH(s string) (*tlshDigest, error) {
+	data, err := hex.DecodeString(strings.TrimPrefix(s, "T1"))
+	if err != nil || len(data) != 3+tlshCodeSize {
+		return nil, fmt.Errorf("invalid TLSH digest: %s", s)
+	}
+	return &tlshDigest{
+		checksum: swapNibbles(data[0]),
+		length:   swapNibbles(data[1]),
+		q1:       data[2] >> 4,
+		q2:       data[2] & 0xf,
+		code:     data[3:],
+	}, nil
+}
+
+// Circular distance
+func modDiff(x, y, r int) int {
+	d := max(x, y) - min(x, y)
+	return min(d, r-d)
+}
+
+// Distance between two TLSH digests: 0 for identical files & rarely over 300 for similar ones
+func tlshDistance(s1, s2 string) (int, error) {
+	a, err := parseTLSH(s1)
+	if err != nil {
+		return 0, err
+	}
+	b, err := parseTLSH(s2)
+	if err != nil {
+		return 0, err
+	}
+	var diff int
+	if d := modDiff(int(a.length), int(b.length), 256); d <= 1 {
+		diff = d
+	} else {
+		diff = d * 12
+	}
+	for _, q := range [][2]byte{{a.q1, b.q1}, {a.q2, b.q2}} {
+		if d := modDiff(int(q[0]), int(q[1]), 16); d <= 1 {
+			diff += d
+		} else {
+			diff += (d - 1) * 12
+		}
+	}
+	if a.checksum != b.checksum {
+		diff++
+	}
+	for i := range a.code {
+		for x, y := a.code[i], b.code[i]; x != 0 || y != 0; x, y = x>>2, y>>2 {
+			// Buckets in opposite quartiles count double
+			if d := max(x&3, y&3) - min(x&3, y&3); d == 3 {
+				diff += 6
+			} else {
+				diff += int(d)
+			}
+		}
+	}
+	return diff, nil
+}
@@ -0,0 +1,119 @@
+package main
+
+import (
+	"math/rand/v2"
+	"strings"
+	"testing"
+)
+
+func Test_tlshLength(t *testing.T) {
+	// Values from the topval table of the reference implementation
+	xwant := map[uint64]byte{
+		1:    0,
+		656:  15,
+		657:  16,
+		3171: 21,
+		3172: 22,
+		3475: 22,
+		3476: 23,
+	}
+	for n, want := range xwant {
+		if got := tlshLength(n); got != want {
+			t.Errorf("tlshLength(%d) got %d; want %d", n, got, want)
+		}
+	}
+}
+
+func Test_tlsh(t *testing.T) {
+	random := rand.New(rand.NewPCG(1, 2))
+	data := make([]byte, 4096)
+	for i := range data {
+		data[i] = byte(random.IntN(256))
+	}
+	digest := func(data []byte) string {
+		h := newTLSH()
+		h.Write(data)
+		return string(h.Sum(nil))
+	}
+
+	d1 := digest(data)
+	if len(d1) != tlshDigestSize || !strings.HasPrefix(d1, "T1") {
+		t.Fatalf("tlsh() got %s", d1)
+	}
+	if d := digest(data[:tlshMinLength-1]); d != tlshNull {
+		t.Errorf("tlsh() on short data got %s; want %s", d, tlshNull)
+	}
+	if d := digest(make([]byte, 1024)); d != tlshNull {
+		t.Errorf("tlsh() on zeroes got %s; want %s", d, tlshNull)
+	}
+
+	similar := append([]byte{}, data...)
+	copy(similar[1000:], "similar")
+	different := make([]byte, len(data))
+	for i := range different {
+		different[i] = byte(random.IntN(256))
+	}
+
+	if score, err := tlshDistance(d1, d1); err != nil || score != 0 {
+		t.Errorf("tlshDistance() of the same digest got %d, %v; want 0", score, err)
+	}
+	near, err := tlshDistance(d1, digest(similar))
+	if err != nil {
+		t.Fatal(err)
+	}
+	far, err := tlshDistance(d1, digest(different))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if near == 0 || near >= far {
+		t.Errorf("tlshDistance() got %d for similar data & %d for different data", near, far)
+	}
+	if _, err := tlshDistance(d1, "T1XYZ"); err == nil {
+		t.Error("tlshDistance() with an invalid digest should fail")
+	}
+}
+
+// Known answers from the reference implementation (tlsh -r), as used by
+// the test suite of github.com/glaslos/tlsh
+func Test_tlshKnownAnswers(t *testing.T) {
+	data := strings.Repeat("MIT License is so cool license that I can't imagine a better one!!\n", 4)
+	h := newTLSH()
+	h.Write([]byte(data))
+	want := "T18ED02202FC30802303A002B03B33300FC30A82F83008C2FA000A0080B8BA0E02CCA0C3"
+	if got := string(h.Sum(nil)); got != want {
+		t.Errorf("tlsh() got %s; want %s", got, want)
+	}
+
+	digests := []string{
+		"T18ED02202FC30802303A002B03B33300FC30A82F83008C2FA000A0080B8BA0E02CCA0C3",
+		"T1B2319634F5C033244EB792AA3168A366E737553DA305A28440CE842D7B57A2CC63B6EC",
+		"T1EA31834386C503B62A920319BA4F92D3BF6FC2B863384515A4EA5638450BC1E9376AE9",
+		"T185C2F1CE3D989428683106EBE5EAAAC924F2D5020B38B1550DA8E5F0DD8C65DECF7037",
+		"T1F7A433B5648BCC69DD48E1DDF1A1876C56E08C0BB264438FAB412C4686FA3F3DB05E36",
+	}
+	for _, test := range []struct{ x, y, want int }{
+		{0, 1, 418},
+		{0, 4, 1014},
+		{2, 0, 374},
+		{2, 4, 967},
+		{3, 4, 619},
+	} {
+		got, err := tlshDistance(digests[test.x], digests[test.y])
+		if err != nil || got != test.want {
+			t.Errorf("tlshDistance(%s, %s) got %d, %v; want %d", digests[test.x], digests[test.y], got, err, test.want)
+		}
+	}
+}
+
+func Test_modDiff(t *testing.T) {
+	for _, test := range []struct{ x, y, want int }{
+		{1, 3, 2},
+		{3, 1, 2},
+		{0, 15, 1},
+		{2, 10, 8},
+	} {
+		if got := modDiff(test.x, test.y, 16); got != test.want {
+			t.Errorf("modDiff(%d, %d) got %d; want %d", test.x, test.y, got, test.want)
+		}
+	}
+}
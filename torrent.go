@@ -0,0 +1,217 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"log"
+	"path/filepath"
+	"slices"
+	"strconv"
+)
+
+// BEP 52 hashes files in blocks of 16 KiB as leaves of a SHA-256 Merkle tree
+const btv2BlockSize = 16 << 10
+
+type btv2Hash struct {
+	block  hash.Hash
+	n      int
+	leaves [][]byte
+}
+
+func newBTv2() hash.Hash {
+	return &btv2Hash{block: sha256.New()}
+}
+
+func (b *btv2Hash) Write(p []byte) (int, error) {
+	written := len(p)
+	for len(p) > 0 {
+		n := min(len(p), btv2BlockSize-b.n)
+		b.block.Write(p[:n])
+		b.n += n
+		p = p[n:]
+		if b.n == btv2BlockSize {
+			b.leaves = append(b.leaves, b.block.Sum(nil))
+			b.block.Reset()
+			b.n = 0
+		}
+	}
+	return written, nil
+}
+
+// The root of the tree with the leaves beyond the end of the file set to zero
+func (b *btv2Hash) Sum(in []byte) []byte {
+	layer := slices.Clone(b.leaves)
+	if b.n > 0 {
+		layer = append(layer, b.block.Sum(nil))
+	}
+	if len(layer) == 0 {
+		return append(in, make([]byte, sha256.Size)...)
+	}
+	for len(layer)&(len(layer)-1) != 0 {
+		layer = append(layer, make([]byte, sha256.Size))
+	}
+	for len(layer) > 1 {
+		for i := range len(layer) / 2 {
+			h := sha256.New()
+			h.Write(layer[2*i])
+			h.Write(layer[2*i+1])
+			layer[i] = h.Sum(nil)
+		}
+		layer = layer[:len(layer)/2]
+	}
+	return append(in, layer[0]...)
+}
+
+func (b *btv2Hash) Reset() {
+	b.block.Reset()
+	b.n = 0
+	b.leaves = nil
+}
+
+func (b *btv2Hash) Size() int      { return sha256.Size }
+func (b *btv2Hash) BlockSize() int { return btv2BlockSize }
+
+// Decode bencoded data into int64, string, []any & map[string]any
+func bdecode(data []byte) (any, []byte, error) {
+	if len(data) == 0 {
+		return nil, nil, errors.New("bencode: unexpected end of data")
+	}
+	switch c := data[0]; {
+	case c == 'i':
+		end := bytes.IndexByte(data, 'e')
+		if end == -1 {
+			return nil, nil, errors.New("bencode: unterminated integer")
+		}
+		n, err := strconv.ParseInt(string(data[1:end]), 10, 64)
+		return n, data[end+1:], err
+	case c == 'l':
+		var list []any
+		data = data[1:]
+		for len(data) > 0 && data[0] != 'e' {
+			var item any
+			var err error
+			if item, data, err = bdecode(data); err != nil {
+				return nil, nil, err
+			}
+			list = append(list, item)
+		}
+		if len(data) == 0 {
+			return nil, nil, errors.New("bencode: unterminated list")
+		}
+		return list, data[1:], nil
+	case c == 'd':
+		dict := make(map[string]any)
+		data = data[1:]
+		for len(data) > 0 && data[0] != 'e' {
+			var key, value any
+			var err error
+			if key, data, err = bdecode(data); err != nil {
+				return nil, nil, err
+			}
+			k, ok := key.(string)
+			if !ok {
+				return nil, nil, errors.New("bencode: dictionary key is not a string")
+			}
+			if value, data, err = bdecode(data); err != nil {
+				return nil, nil, err
+			}
+			dict[k] = value
+		}
+		if len(data) == 0 {
+			return nil, nil, errors.New("bencode: unterminated dictionary")
+		}
+		return dict, data[1:], nil
+	case c >= '0' && c <= '9':
+		colon := bytes.IndexByte(data, ':')
+		if colon == -1 {
+			return nil, nil, errors.New("bencode: invalid string")
+		}
+		n, err := strconv.Atoi(string(data[:colon]))
+		if err != nil || n < 0 || colon+1+n > len(data) {
+			return nil, nil, errors.New("bencode: invalid string length")
+		}
+		return string(data[colon+1 : colon+1+n]), data[colon+1+n:], nil
+	}
+	return nil, nil, fmt.Errorf("bencode: invalid character %q", data[0])
+}
+
+// Walk the "file tree" of a v2 torrent where files are dictionaries with an empty key
+func torrentFiles(tree map[string]any, dir string, fn func(file string, root []byte)) {
+	for name, node := range tree {
+		node, ok := node.(map[string]any)
+		if !ok {
+			continue
+		}
+		if file, ok := node[""].(map[string]any); ok {
+			// Empty files have no pieces root
+			if root, ok := file["pieces root"].(string); ok && len(root) == sha256.Size {
+				fn(filepath.Join(dir, name), []byte(root))
+			}
+			continue
+		}
+		torrentFiles(node, filepath.Join(dir, name), fn)
+	}
+}
+
+// Get the "file tree" of a v2 torrent & the directory of its files
+func torrentTree(metainfo any) (tree map[string]any, dir string, ok bool) {
+	dict, ok := metainfo.(map[string]any)
+	if !ok {
+		return nil, "", false
+	}
+	info, ok := dict["info"].(map[string]any)
+	if !ok {
+		return nil, "", false
+	}
+	if tree, ok = info["file tree"].(map[string]any); !ok {
+		return nil, "", false
+	}
+
+	// Multi-file torrents have their files inside a directory with the name of the torrent
+	root, _ := info["name"].(string)
+	if node, _ := tree[root].(map[string]any); len(tree) > 1 || node == nil || node[""] == nil {
+		dir = root
+	}
+	return tree, dir, true
+}
+
+// Used by the -c option with a .torrent file
+func inputFromTorrent(f io.ReadCloser, name string) <-chan *Checksums {
+	files := make(chan *Checksums, chanSize)
+
+	go func() {
+		defer close(files)
+		defer f.Close()
+
+		data, err := io.ReadAll(f)
+		if err != nil {
+			log.Fatal(err)
+		}
+		metainfo, _, err := bdecode(data)
+		if err != nil {
+			log.Fatal(err)
+		}
+		tree, dir, ok := torrentTree(metainfo)
+		if !ok {
+			log.Fatalf("%s is not a BitTorrent v2 torrent", name)
+		}
+
+		torrentFiles(tree, dir, func(file string, root []byte) {
+			files <- &Checksums{
+				file: file,
+				checksums: []*Checksum{
+					{
+						hash: BTV2,
+						csum: root,
+					},
+				},
+			}
+		})
+	}()
+
+	return files
+}
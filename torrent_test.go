@@ -0,0 +1,78 @@
+package main
+
+import (
+	"encoding/hex"
+	"io"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+)
+
+func Test_btv2Hash(t *testing.T) {
+	xwant := map[string]string{
+		// A single block is its own root
+		"hello": "2cf24dba5fb0a30e26e83b2ac5b9e29e1b161e5c1fa7425e73043362938b9824",
+		// Three blocks padded with a zero leaf
+		strings.Repeat("a", 40000):           "225106564456ed33b02cc22e9d6f5014fd9f4c5383bee6605e07664a44d260ea",
+		strings.Repeat("a", btv2BlockSize*4): "92157cae1e6def216a25d2d97d6078a494e3479f36da0887a7c37c164925cc61",
+	}
+	for str, want := range xwant {
+		h := newBTv2()
+		h.Write([]byte(str))
+		if got := hex.EncodeToString(h.Sum(nil)); got != want {
+			t.Errorf("btv2(%d bytes) got %s; want %s", len(str), got, want)
+		}
+	}
+}
+
+func Test_bdecode(t *testing.T) {
+	got, rest, err := bdecode([]byte("d3:bar4:spam3:fooi42e4:listl1:ai-1eee"))
+	want := map[string]any{"bar": "spam", "foo": int64(42), "list": []any{"a", int64(-1)}}
+	if err != nil || len(rest) != 0 || !reflect.DeepEqual(got, want) {
+		t.Errorf("bdecode() got %v, %q, %v; want %v", got, rest, err, want)
+	}
+	for _, str := range []string{"", "d3:foo", "l1:a", "i42", "5:abc", "x"} {
+		if _, _, err := bdecode([]byte(str)); err == nil {
+			t.Errorf("bdecode(%q) should fail", str)
+		}
+	}
+}
+
+func Test_inputFromTorrent(t *testing.T) {
+	root := strings.Repeat("\x01", 32)
+	file := func(length int) string {
+		return "d0:d6:lengthi" + string(rune('0'+length)) + "e11:pieces root32:" + root + "ee"
+	}
+	xwant := map[string][]string{
+		// Multi-file torrent with an empty file
+		"d4:infod9:file treed1:a" + file(1) + "3:subd1:b" + file(2) + "e5:emptyd0:d6:lengthi0eeee4:name2:dsee": {"ds/a", "ds/sub/b"},
+		// Single-file torrent
+		"d4:infod9:file treed1:f" + file(3) + "e4:name1:fee": {"f"},
+	}
+	for torrent, want := range xwant {
+		var got []string
+		for input := range inputFromTorrent(io.NopCloser(strings.NewReader(torrent)), "test.torrent") {
+			if input.checksums[0].hash != BTV2 || string(input.checksums[0].csum) != root {
+				t.Errorf("inputFromTorrent() got %v", input.checksums[0])
+			}
+			got = append(got, input.file)
+		}
+		sort.Strings(got)
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("inputFromTorrent() got %v; want %v", got, want)
+		}
+	}
+}
+
+func Test_torrentTree(t *testing.T) {
+	for _, str := range []string{"i1e", "le", "d4:infoi1ee", "d4:infod4:name1:fee", "d4:infod9:file tree3:fooee"} {
+		metainfo, _, err := bdecode([]byte(str))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, _, ok := torrentTree(metainfo); ok {
+			t.Errorf("torrentTree(%q) should fail", str)
+		}
+	}
+}
@@ -3,6 +3,9 @@ package main
 import (
 	"crypto"
 	"hash"
+	"hash/crc32"
+	"io"
+	"os"
 	"regexp"
 	"testing/fstest"
 	"text/template"
@@ -29,39 +32,76 @@ type Checksums struct {
 	file      string
 	size      Size
 	checksums []*Checksum
+	offset    Size         // Used by the --offset & --piece-size options
+	pieces    []*Checksums // Used by the --piece-size option
 }
 
 // Output type with available fields
 type Output struct {
-	Name string
-	File string
-	Sum  string
+	Name   string
+	File   string
+	Sum    string
+	Offset Size
+	Length Size
 }
 
 // Constants for hashes not in stdlib
 const (
 	_ crypto.Hash = 30 + iota // Don't conflict with https://pkg.go.dev/crypto#Hash
 	BLAKE3
+	CRC32C
+	DROPBOX
+	BTV2
+	ED2K
+	TTH
+	SSDEEP
+	TLSH
 )
 
+// Names for hashes not in stdlib
+var extraHashes = map[crypto.Hash]string{
+	BLAKE3:  "BLAKE3",
+	BTV2:    "BTV2",
+	CRC32C:  "CRC32C",
+	DROPBOX: "DROPBOX",
+	ED2K:    "ED2K",
+	SSDEEP:  "SSDEEP",
+	TLSH:    "TLSH",
+	TTH:     "TTH",
+}
+
 // Keep alphabetically sorted
 var hashes = []crypto.Hash{
 	crypto.BLAKE2b_256,
 	crypto.BLAKE2b_512,
 	crypto.BLAKE2s_256,
 	BLAKE3,
+	BTV2,
+	CRC32C,
+	DROPBOX,
+	ED2K,
+	crypto.MD4,
 	crypto.MD5,
 	crypto.SHA1,
 	crypto.SHA256,
+	crypto.SHA384,
 	crypto.SHA512,
 	crypto.SHA512_256,
 	crypto.SHA3_256,
 	crypto.SHA3_512,
+	SSDEEP,
+	TLSH,
+	TTH,
 }
 
+// Hashes not selected by --all because they're only used by their own modes
+// or, like the fuzzy hashes, can't be checked for equality
+var specialHashes = []crypto.Hash{BTV2, CRC32C, DROPBOX, ED2K, SSDEEP, TLSH, TTH}
+
 var (
 	algorithms map[crypto.Hash]*Algorithm
 	chosen     []crypto.Hash
+	castagnoli = crc32.MakeTable(crc32.Castagnoli)
 	format     = template.New("format")
 	fsys       fstest.MapFS
 	macKey     []byte
@@ -69,28 +109,83 @@ var (
 	gnuFormat  string = "{{range .}}{{.Sum}}  {{.File}}\n{{end}}"
 )
 
+// Where checksums are printed, changed by the -o, --tee & -p options
+var stdout io.Writer = os.Stdout
+
 type Options struct {
-	all            bool
-	base64         bool
-	check          string
-	format         string
-	dummy          bool // Used to support unsupported options
-	gnu            bool
-	input          string
-	ignore         bool
-	key            string
-	size           bool
-	followSymlinks bool // Used by the -r option
-	quiet          bool // Used by the -c option
-	recursive      bool
-	status         bool // Used by the -c option
-	strict         bool // Used by the -c option
-	str            bool
-	tag            bool
-	verbose        bool // Used by the -c option
-	version        bool
-	warn           bool // Used by the -c option
-	zero           bool
+	all             bool
+	allowOutside    bool // Used by the --base-dir & --strip options
+	archive         bool
+	base64          bool
+	baseDir         string
+	check           string
+	checkExtra      string
+	checkPerDir     string
+	cid             bool
+	cdc             *cdcParams
+	cdcStr          string
+	cloud           string
+	compare         bool
+	copyTo          string
+	decompress      bool
+	dirhash         bool
+	duplicates      bool
+	dupScript       string
+	dmverity        bool
+	etag            bool
+	format          string
+	fuzzyMatch      string
+	fsverity        bool
+	dummy           bool // Used to support unsupported options
+	git             bool
+	gnu             bool
+	input           string
+	ignore          bool
+	key             string
+	length          Size
+	lengthStr       string
+	magnet          bool
+	match           string
+	modcache        string
+	module          string
+	multibase       string
+	multihash       bool
+	nar             bool
+	nix32           bool
+	noMatch         string
+	offset          Size
+	offsetStr       string
+	output          string
+	passthrough     bool
+	perDir          string
+	partSize        Size
+	partSizeStr     string
+	pieceSize       Size
+	pieceSizeStr    string
+	preserve        bool // Used by the --copy-to option
+	resume          string
+	size            bool
+	sri             bool
+	ssdeepThreshold int
+	followSymlinks  bool // Used by the -r option
+	quiet           bool // Used by the -c option
+	recursive       bool
+	rehash          bool // Used by the --update option
+	status          bool // Used by the -c option
+	strict          bool // Used by the -c option
+	str             bool
+	strip           int
+	tag             bool
+	tee             []string
+	tlshThreshold   int
+	update          string
+	verbose         bool // Used by the -c option
+	verityBlockSize int
+	veritySalt      []byte
+	veritySaltStr   string
+	version         bool
+	warn            bool // Used by the -c option
+	zero            bool
 }
 
 var opts Options
@@ -110,16 +205,22 @@ var better = []crypto.Hash{
 	BLAKE3,
 	crypto.BLAKE2b_512,
 	crypto.BLAKE2b_256,
-	crypto.SHA512,     // SHA512 is faster than SHA256 on some architectures
-	crypto.SHA512_256, // Truncated SHA512 has security against length extension attacks
+	crypto.SHA512, // SHA512 is faster than SHA256 on some architectures
+	crypto.SHA384, // Truncated SHA512 has security against length extension attacks
+	crypto.SHA512_256,
 	crypto.SHA256,
 	// SHA-3 are slow
 	crypto.SHA3_256,
 	crypto.SHA3_512,
+	DROPBOX, // Content hash based on SHA256 that can't be computed in parallel
+	BTV2,    // Merkle tree based on SHA256
+	TTH,     // Merkle tree based on Tiger
 	// These are insecure
 	crypto.SHA1,
 	crypto.MD5,
 	crypto.MD4,
+	ED2K,   // Based on MD4
+	CRC32C, // Not even a cryptographic hash
 }
 
 var regex = struct {
@@ -128,20 +229,22 @@ var regex = struct {
 	regexp.MustCompile(`^[g-zG-Z/+]`),
 	// Format used by OpenSSL dgst, BSD digest & Solaris digest
 	// NOTE: The backslash is added by ourselves if escape the filename
-	regexp.MustCompile(`(?s)^([A-Za-z]+[a-z0-9-]*) ?\((.*?)\) ?= ([0-9a-zA-Z/+]{16,}={0,2})$`),
+	regexp.MustCompile(`(?s)^([A-Za-z]+[a-z0-9-]*) ?\((.*?)\) ?= ([0-9a-zA-Z/+_-]{16,}={0,2})$`),
 	// Format used by GNU *sum
-	regexp.MustCompile(`(?s)^\\?([0-9a-zA-Z/+]{16,}={0,2}) [ \*](.*)$`),
+	regexp.MustCompile(`(?s)^\\?([0-9a-zA-Z/+_-]{16,}={0,2}) [ \*](.*)$`),
 	// Format used by Docker distribution digest
 	regexp.MustCompile(`(?s)^([A-Za-z]+[a-z0-9-]*):([0-9a-zA-Z/+]{16,}) (.*)`),
 }
 
 var (
-	insecure  = []crypto.Hash{crypto.MD4, crypto.MD5, crypto.RIPEMD160, crypto.SHA1}
+	insecure  = []crypto.Hash{crypto.MD4, crypto.MD5, crypto.RIPEMD160, crypto.SHA1, CRC32C, ED2K}
 	size2hash = map[int]string{
 		crypto.SHA512.Size(): "SHA512",
+		crypto.SHA384.Size(): "SHA384",
 		crypto.SHA256.Size(): "SHA256",
 		crypto.SHA1.Size():   "SHA1",
 		crypto.MD5.Size():    "MD5",
+		tigerSize:            "TTH",
 	}
 )
 
@@ -152,6 +255,7 @@ var name2Hash = map[string]crypto.Hash{
 	"BLAKE2B-512": crypto.BLAKE2b_512, // Used by OpenSSL's dgst
 	"BLAKE2S-256": crypto.BLAKE2s_256, // Used by OpenSSL's dgst
 	"SHA2-256":    crypto.SHA256,      // Used by OpenSSL's dgst
+	"SHA2-384":    crypto.SHA384,      // Used by OpenSSL's dgst
 	"SHA2-512":    crypto.SHA512,      // Used by OpenSSL's dgst
 	"SHA3-256":    crypto.SHA3_256,    // Used by OpenSSL's dgst
 	"SHA3-512":    crypto.SHA3_512,    // Used by OpenSSL's dgst
@@ -0,0 +1,216 @@
+package main
+
+import (
+	"bytes"
+	"crypto"
+	"encoding/hex"
+	"fmt"
+	"io/fs"
+	"log"
+	"os"
+	"runtime"
+	"slices"
+	"sync/atomic"
+	"time"
+
+	"golang.org/x/sync/errgroup"
+)
+
+// Entry of a manifest refreshed by the --update option
+type updateEntry struct {
+	file    string
+	old     *Checksums // Nil for new files
+	results *Checksums // Nil until hashed, or if hashing failed
+}
+
+// Line format of an existing manifest, used by the --update option unless --gnu or --format are given,
+// and whether it lists sizes
+func manifestFormat(file string) (gnu, base64, size bool) {
+	f, err := os.Open(file)
+	if err != nil {
+		return false, false, false
+	}
+	defer f.Close()
+
+	scanner, err := getScanner(f, opts.zero)
+	if err != nil {
+		log.Fatal(err)
+	}
+	isBase64 := func(digest string) bool {
+		_, err := hex.DecodeString(digest)
+		return err != nil && len(digest) != tthBase32Len
+	}
+	for scanner.Scan() {
+		line := scanner.Text()
+		if _, _, ok := parseSizeLine(line, opts.zero); ok {
+			size = true
+		} else if match := regex.bsd.FindStringSubmatch(line); match != nil {
+			return false, isBase64(match[3]), size
+		} else if match := regex.gnu.FindStringSubmatch(line); match != nil {
+			return true, isBase64(match[1]), size
+		}
+	}
+	return false, false, size
+}
+
+// Whether the checksums are for exactly the chosen algorithms, as digests guessed from their size may be for others
+func hasChosen(checksums []*Checksum) bool {
+	return len(checksums) == len(chosen) && !slices.ContainsFunc(checksums, func(checksum *Checksum) bool {
+		h := &Checksum{hash: checksum.hash}
+		initHash(h)
+		return !slices.Contains(chosen, checksum.hash) || len(checksum.csum) != h.Size()
+	})
+}
+
+// Whether the computed checksums are those in the manifest
+func sameSums(checksums []*Checksum) bool {
+	return !slices.ContainsFunc(checksums, func(checksum *Checksum) bool {
+		return !bytes.Equal(checksum.sum, checksum.csum)
+	})
+}
+
+// Read the entries of a manifest with all their algorithms
+func readUpdateManifest(manifest string) (entries []*Checksums, mtime time.Time, mode fs.FileMode, err error) {
+	mode = 0o644
+	info, err := os.Stat(manifest)
+	if os.IsNotExist(err) {
+		return nil, mtime, mode, nil
+	} else if err != nil {
+		return nil, mtime, mode, err
+	}
+	f, err := os.Open(manifest)
+	if err != nil {
+		return nil, mtime, mode, err
+	}
+	if entries, err = readChecksums(f, opts.zero, errorAction()); err != nil {
+		return nil, mtime, mode, err
+	}
+	return entries, info.ModTime(), info.Mode().Perm(), nil
+}
+
+// Refresh a manifest in place: keep entries of files not modified after the manifest and with the listed size, if any,
+// rehash the others, drop those of deleted files & add new files.  The manifest is replaced atomically.
+func updateManifest(manifest string, files <-chan *Checksums) error {
+	entries, mtime, mode, err := readUpdateManifest(manifest)
+	if err != nil {
+		return err
+	}
+
+	// Default to the algorithms already used
+	if len(chosen) == 0 {
+		for _, h := range hashes {
+			if slices.ContainsFunc(entries, func(entry *Checksums) bool {
+				return slices.ContainsFunc(entry.checksums, func(checksum *Checksum) bool { return checksum.hash == h })
+			}) {
+				chosen = append(chosen, h)
+			}
+		}
+		if len(chosen) == 0 {
+			chosen = append(chosen, crypto.SHA256)
+		}
+	}
+
+	var removed int
+	var updates []*updateEntry
+	seen := map[string]bool{normalizePath(manifest): true}
+	for _, entry := range entries {
+		if key := normalizePath(entry.file); seen[key] {
+			continue
+		} else {
+			seen[key] = true
+		}
+		// Pieces & archive members reference a file
+		file := listedFile(entry.file)
+		seen[normalizePath(file)] = true
+		info, err := os.Stat(file)
+		if os.IsNotExist(err) {
+			removed++
+			continue
+		}
+		update := &updateEntry{file: entry.file, old: entry}
+		// Sizes of pieces & archive members are not those of the file
+		sameSize := entry.size < 0 || file != entry.file || entry.size == info.Size()
+		if err == nil && !opts.rehash && !info.ModTime().After(mtime) && sameSize && hasChosen(entry.checksums) {
+			for _, checksum := range entry.checksums {
+				checksum.sum = checksum.csum
+			}
+			if file == entry.file {
+				entry.size = info.Size()
+			}
+			update.results = entry
+		}
+		updates = append(updates, update)
+	}
+	for file := range files {
+		if key := normalizePath(file.file); !seen[key] {
+			seen[key] = true
+			updates = append(updates, &updateEntry{file: file.file})
+		}
+	}
+
+	var unreadable atomic.Uint64
+	g := new(errgroup.Group)
+	g.SetLimit(runtime.NumCPU())
+	for _, update := range updates {
+		if update.results != nil {
+			continue
+		}
+		g.Go(func() error {
+			results, err := hashFileOrPiece(update.file, nil)
+			if err != nil {
+				log.Print(err)
+				unreadable.Add(1)
+			}
+			update.results = results
+			return nil
+		})
+	}
+	if err := g.Wait(); err != nil {
+		return err
+	}
+
+	var added, changed, kept int
+	err = writeChecksums(manifest, mode, func() {
+		for _, update := range updates {
+			results := update.results
+			switch {
+			case results == nil && update.old == nil:
+				// New file that couldn't be read
+				continue
+			case results == nil:
+				// Keep the entry of a file that couldn't be read
+				for _, checksum := range update.old.checksums {
+					checksum.sum = checksum.csum
+				}
+				results = update.old
+			case update.old == nil:
+				added++
+			case results == update.old:
+				kept++
+			default:
+				for _, checksum := range results.checksums {
+					if i := slices.IndexFunc(update.old.checksums, func(c *Checksum) bool { return c.hash == checksum.hash }); i >= 0 {
+						checksum.csum = update.old.checksums[i].csum
+					}
+				}
+				if hasChosen(update.old.checksums) && sameSums(results.checksums) {
+					kept++
+				} else {
+					changed++
+				}
+			}
+			printChecksums(results, opts)
+		}
+	})
+	if err != nil {
+		return err
+	}
+
+	if !opts.status {
+		fmt.Fprintf(os.Stderr, "Added: %d, Updated: %d, Removed: %d, Unchanged: %d\n", added, changed, removed, kept)
+	}
+	if n := unreadable.Load(); n > 0 {
+		return fmt.Errorf("%d files could not be read", n)
+	}
+	return nil
+}
@@ -0,0 +1,146 @@
+package main
+
+import (
+	"crypto"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"text/template"
+	"time"
+)
+
+func Test_updateManifest(t *testing.T) {
+	oldChosen, oldFormat := chosen, format
+	defer func() { chosen, format = oldChosen, oldFormat }()
+	chosen = nil
+	format = template.Must(template.New("format").Parse(gnuFormat))
+
+	dir := t.TempDir()
+	write := func(name, data string, mtime time.Time) string {
+		file := filepath.Join(dir, name)
+		if err := os.WriteFile(file, []byte(data), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Chtimes(file, mtime, mtime); err != nil {
+			t.Fatal(err)
+		}
+		return file
+	}
+	before, after := time.Now().Add(-time.Hour), time.Now().Add(time.Hour)
+
+	// MD5 of "a" & "b", while b now has "changed"
+	a := write("a", "a", before)
+	b := write("b", "changed", after)
+	manifest := write("MD5SUMS", strings.Join([]string{
+		"0cc175b9c0f1b6a831c399e269772661  " + a,
+		"92eb5ffee6ae2fec3ad71c777531578f  " + b,
+		"00000000000000000000000000000000  " + filepath.Join(dir, "deleted"),
+		"",
+	}, "\n"), time.Now())
+	c := write("c", "c", before)
+
+	files := make(chan *Checksums, 2)
+	files <- &Checksums{file: a}
+	files <- &Checksums{file: c}
+	close(files)
+	if err := updateManifest(manifest, files); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(chosen) != 1 || chosen[0] != crypto.MD5 {
+		t.Errorf("updateManifest() chose %v; want [MD5]", chosen)
+	}
+	got, err := os.ReadFile(manifest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := strings.Join([]string{
+		"0cc175b9c0f1b6a831c399e269772661  " + a,
+		"8977dfac2f8e04cb96e66882235f5aba  " + b,
+		"4a8a08f09d37b73795649038408b5f33  " + c,
+		"",
+	}, "\n")
+	if string(got) != want {
+		t.Errorf("updateManifest() got\n%s\nwant\n%s", got, want)
+	}
+}
+
+func Test_updateManifestMixed(t *testing.T) {
+	oldChosen, oldFormat, oldSize := chosen, format, opts.size
+	defer func() { chosen, format, opts.size = oldChosen, oldFormat, oldSize }()
+	chosen = nil
+	format = template.Must(template.New("format").Parse(bsdFormat))
+	opts.size = true
+
+	dir := t.TempDir()
+	write := func(name, data string, mtime time.Time) string {
+		file := filepath.Join(dir, name)
+		if err := os.WriteFile(file, []byte(data), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Chtimes(file, mtime, mtime); err != nil {
+			t.Fatal(err)
+		}
+		return file
+	}
+	before := time.Now().Add(-time.Hour)
+
+	// SHA256 & SHA512 of "a" & "b", while b now has "bb" but an old modification time
+	sums := map[string][]string{
+		"a":  {"ca978112ca1bbdcafac231b39a23dc4da786eff8147c4e72b9807785afee48bb", "1f40fc92da241694750979ee6cf582f2d5d7d28e18335de05abc54d0560e0f5302860c652bf08d560252aa5e74210546f369fbbbce8c12cfc7957b2652fe9a75"},
+		"b":  {"3e23e8160039594a33894f6564e1b1348bbd7a0088d42c4acb73eeaed59c009d", "5267768822ee624d48fce15ec5ca79cbd602cb7f4c2157a516556991f22ef8c7b5ef7b18d1ff41c59370efb0858651d44a936c11b7b144c48fe04df3c6a3e8da"},
+		"bb": {"3b64db95cb55c763391c707108489ae18b4112d783300de38e033b4c98c3deaf", "24b1a812d4e3535c06011c430aaba3f59d32f36263ddcb99541f998266c5e84a52fb33f951cec78656f598a004f83c771388b9a80404f7432b714f4dcae4a00f"},
+	}
+	a := write("a", "a", before)
+	b := write("b", "bb", before)
+	lines := func(file, data string) []string {
+		return []string{
+			"SIZE (" + file + ") = " + strconv.Itoa(len(data)),
+			"SHA256 (" + file + ") = " + sums[data][0],
+			"SHA512 (" + file + ") = " + sums[data][1],
+		}
+	}
+	manifest := write("SUMS", strings.Join(append(append(lines(a, "a"), lines(b, "b")...), ""), "\n"), time.Now())
+
+	files := make(chan *Checksums)
+	close(files)
+	if err := updateManifest(manifest, files); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(chosen) != 2 || chosen[0] != crypto.SHA256 || chosen[1] != crypto.SHA512 {
+		t.Errorf("updateManifest() chose %v; want [SHA256 SHA512]", chosen)
+	}
+	got, err := os.ReadFile(manifest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := strings.Join(append(append(lines(a, "a"), lines(b, "bb")...), ""), "\n")
+	if string(got) != want {
+		t.Errorf("updateManifest() got\n%s\nwant\n%s", got, want)
+	}
+}
+
+func Test_manifestFormat(t *testing.T) {
+	dir := t.TempDir()
+	tests := []struct {
+		line              string
+		gnu, base64, size bool
+	}{
+		{"SHA256 (file) = ba7816bf8f01cfea414140de5dae2223b00361a396177a9cb410ff61f20015ad", false, false, false},
+		{"ba7816bf8f01cfea414140de5dae2223b00361a396177a9cb410ff61f20015ad  file", true, false, false},
+		{"ungWv48Bz+pBQUDeXa4iI7ADYaOWF3qctBD/YfIAFa0=  file", true, true, false},
+		{"SIZE (file) = 3\nSHA256 (file) = ba7816bf8f01cfea414140de5dae2223b00361a396177a9cb410ff61f20015ad", false, false, true},
+	}
+	for _, tt := range tests {
+		file := filepath.Join(dir, "SUMS")
+		if err := os.WriteFile(file, []byte(tt.line+"\n"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		if gnu, base64, size := manifestFormat(file); gnu != tt.gnu || base64 != tt.base64 || size != tt.size {
+			t.Errorf("manifestFormat(%q) got %v, %v, %v; want %v, %v, %v", tt.line, gnu, base64, size, tt.gnu, tt.base64, tt.size)
+		}
+	}
+}
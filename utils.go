@@ -5,10 +5,13 @@ import (
 	"bytes"
 	"crypto"
 	"crypto/hmac"
+	"fmt"
 	"golang.org/x/crypto/blake2b"
 	"golang.org/x/crypto/blake2s"
 	"hash"
+	"hash/crc32"
 	"io"
+	"math"
 	"strconv"
 	"strings"
 
@@ -36,6 +39,20 @@ func initHash(h *Checksum) {
 		} else {
 			h.Hash = blake3.New()
 		}
+	case CRC32C:
+		h.Hash = crc32.New(castagnoli)
+	case DROPBOX:
+		h.Hash = newDropbox()
+	case BTV2:
+		h.Hash = newBTv2()
+	case ED2K:
+		h.Hash = newED2K()
+	case TTH:
+		h.Hash = newTTH()
+	case SSDEEP:
+		h.Hash = newSSDEEP()
+	case TLSH:
+		h.Hash = newTLSH()
 	case crypto.BLAKE2s_256:
 		h.Hash = blake2(blake2s.New256, macKey)
 	case crypto.BLAKE2b_256:
@@ -129,3 +146,28 @@ func getScanner(f io.Reader, zeroTerminated bool) (*bufio.Scanner, error) {
 
 	return scanner, nil
 }
+
+// Parse sizes like 8388608, 8M or 8MiB using binary multiples
+func parseSize(str string) (Size, error) {
+	var multipliers = map[string]Size{
+		"":  1,
+		"K": 1 << 10,
+		"M": 1 << 20,
+		"G": 1 << 30,
+		"T": 1 << 40,
+	}
+	s := strings.TrimSuffix(strings.TrimSuffix(strings.ToUpper(strings.TrimSpace(str)), "B"), "I")
+	i := strings.IndexFunc(s, func(r rune) bool { return r < '0' || r > '9' })
+	if i == -1 {
+		i = len(s)
+	}
+	multiplier, ok := multipliers[strings.TrimSpace(s[i:])]
+	if !ok {
+		return 0, fmt.Errorf("invalid size: %s", str)
+	}
+	n, err := strconv.ParseInt(s[:i], 10, 64)
+	if err != nil || n > math.MaxInt64/multiplier {
+		return 0, fmt.Errorf("invalid size: %s", str)
+	}
+	return Size(n) * multiplier, nil
+}
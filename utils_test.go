@@ -46,3 +46,26 @@ func Test_unescapeFilename(t *testing.T) {
 		}
 	}
 }
+
+func Test_parseSize(t *testing.T) {
+	xwant := map[string]Size{
+		"0":      0,
+		"1024":   1024,
+		"8M":     8 << 20,
+		"8MiB":   8 << 20,
+		"16k":    16 << 10,
+		"1G":     1 << 30,
+		"100 MB": 100 << 20,
+	}
+	for str, want := range xwant {
+		got, err := parseSize(str)
+		if err != nil || got != want {
+			t.Errorf("parseSize(%q) got %d, %v; want %d", str, got, err, want)
+		}
+	}
+	for _, str := range []string{"", "M", "8X", "-1", "99999999999T"} {
+		if _, err := parseSize(str); err == nil {
+			t.Errorf("parseSize(%q) should fail", str)
+		}
+	}
+}
@@ -0,0 +1,190 @@
+package main
+
+import (
+	"crypto"
+	"encoding/binary"
+	"fmt"
+	"hash"
+	"io"
+	"math/bits"
+	"os"
+)
+
+// Algorithm numbers used by the fs-verity descriptor
+var fsverityHashes = map[crypto.Hash]uint8{
+	crypto.SHA256: 1,
+	crypto.SHA512: 2,
+}
+
+var dmverityHashes = []crypto.Hash{crypto.SHA1, crypto.SHA256, crypto.SHA512}
+
+// Merkle tree built a block at a time as done by fs-verity & dm-verity
+type verityTree struct {
+	hash      hash.Hash
+	blockSize int
+	slotSize  int    // Size of each digest in a hash block
+	prefix    []byte // Salt prepended to each block
+	levels    []*verityLevel
+}
+
+type verityLevel struct {
+	block []byte
+	count int64 // Number of digests added to this level
+	last  []byte
+}
+
+func newVerityTree(h crypto.Hash, blockSize int, prefix []byte, slotSize int) *verityTree {
+	return &verityTree{
+		hash:      h.New(),
+		blockSize: blockSize,
+		slotSize:  slotSize,
+		prefix:    prefix,
+	}
+}
+
+func (tree *verityTree) digest(block []byte) []byte {
+	tree.hash.Reset()
+	tree.hash.Write(tree.prefix)
+	tree.hash.Write(block)
+	return tree.hash.Sum(nil)
+}
+
+// Add a digest to a level, hashing its block to the next level when full
+func (tree *verityTree) add(i int, digest []byte) {
+	if i == len(tree.levels) {
+		tree.levels = append(tree.levels, &verityLevel{block: make([]byte, 0, tree.blockSize)})
+	}
+	level := tree.levels[i]
+	level.count++
+	level.last = digest
+	level.block = append(level.block, digest...)
+	level.block = append(level.block, make([]byte, tree.slotSize-len(digest))...)
+	if len(level.block)+tree.slotSize > tree.blockSize {
+		tree.flush(i)
+	}
+}
+
+func (tree *verityTree) flush(i int) {
+	level := tree.levels[i]
+	block := append(level.block, make([]byte, tree.blockSize-len(level.block))...)
+	level.block = level.block[:0]
+	tree.add(i+1, tree.digest(block))
+}
+
+// Hash a data block which must be padded to the block size
+func (tree *verityTree) write(block []byte) {
+	tree.add(0, tree.digest(block))
+}
+
+// The root hash is the only digest of the first level with one digest
+func (tree *verityTree) root() []byte {
+	for i := 0; i < len(tree.levels); i++ {
+		level := tree.levels[i]
+		if level.count == 1 {
+			return level.last
+		}
+		if len(level.block) > 0 {
+			tree.flush(i)
+		}
+	}
+	// Empty
+	return make([]byte, tree.hash.Size())
+}
+
+// Feed all data blocks of a file to the trees, padding the last one with zeroes if partial is set
+func verityData(file string, trees []*verityTree, partial bool) (Size, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+
+	size, err := fileSize(f)
+	if err != nil {
+		return 0, err
+	}
+	if !partial {
+		// veritysetup ignores trailing bytes that don't fill a block
+		size -= size % Size(opts.verityBlockSize)
+	}
+
+	block := make([]byte, opts.verityBlockSize)
+	r := io.LimitReader(f, size)
+	for {
+		n, err := io.ReadFull(r, block)
+		if n == 0 {
+			break
+		}
+		clear(block[n:])
+		for _, tree := range trees {
+			tree.write(block)
+		}
+		if err != nil {
+			break
+		}
+	}
+	return size, nil
+}
+
+// fs-verity pads the salt to the input block size of the hash
+func fsveritySalt(h crypto.Hash) []byte {
+	if len(opts.veritySalt) == 0 {
+		return nil
+	}
+	blockSize := h.New().BlockSize()
+	return append(opts.veritySalt, make([]byte, (blockSize-len(opts.veritySalt)%blockSize)%blockSize)...)
+}
+
+// The file digest is the hash of struct fsverity_descriptor
+func fsverityDescriptor(h crypto.Hash, size Size, root []byte) []byte {
+	desc := make([]byte, 256)
+	desc[0] = 1 // version
+	desc[1] = fsverityHashes[h]
+	desc[2] = uint8(bits.TrailingZeros(uint(opts.verityBlockSize)))
+	desc[3] = uint8(len(opts.veritySalt))
+	binary.LittleEndian.PutUint64(desc[8:], uint64(size))
+	copy(desc[16:80], root)
+	copy(desc[80:112], opts.veritySalt)
+	return desc
+}
+
+// Used by the --fsverity option
+func hashFSVerity(file string, _ []*Checksum) (*Checksums, error) {
+	trees := make([]*verityTree, len(chosen))
+	for i, h := range chosen {
+		trees[i] = newVerityTree(h, opts.verityBlockSize, fsveritySalt(h), h.Size())
+	}
+	size, err := verityData(file, trees, true)
+	if err != nil {
+		return nil, err
+	}
+	checksums := make([]*Checksum, len(chosen))
+	for i, h := range chosen {
+		d := h.New()
+		d.Write(fsverityDescriptor(h, size, trees[i].root()))
+		checksums[i] = &Checksum{hash: h, sum: d.Sum(nil)}
+	}
+	return &Checksums{file: file, size: size, checksums: checksums}, nil
+}
+
+// Used by the --dmverity option with the format of veritysetup format
+func hashDMVerity(file string, _ []*Checksum) (*Checksums, error) {
+	trees := make([]*verityTree, len(chosen))
+	for i, h := range chosen {
+		// Digests are padded to a power of two in hash blocks
+		slotSize := 1 << bits.Len(uint(h.Size()-1))
+		trees[i] = newVerityTree(h, opts.verityBlockSize, opts.veritySalt, slotSize)
+	}
+	size, err := verityData(file, trees, false)
+	if err != nil {
+		return nil, err
+	}
+	if size == 0 {
+		return nil, fmt.Errorf("%s: smaller than a data block", file)
+	}
+	checksums := make([]*Checksum, len(chosen))
+	for i, h := range chosen {
+		checksums[i] = &Checksum{hash: h, sum: trees[i].root()}
+	}
+	return &Checksums{file: file, size: size, checksums: checksums}, nil
+}
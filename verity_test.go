@@ -0,0 +1,83 @@
+package main
+
+import (
+	"crypto"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func Test_verity(t *testing.T) {
+	dir := t.TempDir()
+
+	oldChosen, oldOpts := chosen, opts
+	defer func() { chosen, opts = oldChosen, oldOpts }()
+
+	// The empty file digest matches the one from fsverity digest
+	xwant := []struct {
+		size      int
+		hash      crypto.Hash
+		salt      string
+		blockSize int
+		fsverity  string
+		dmverity  string
+	}{
+		{0, crypto.SHA256, "", 4096, "3d248ca542a24fc62d1c43b916eae5016878e2533c88238480b26128a1f1af95", ""},
+		{5, crypto.SHA256, "", 4096, "6fec08473ebeaadaefc5998685388b1cf7a26775c0157c5ca7d7f32fe73a7010", ""},
+		{5, crypto.SHA512, "73616c74", 4096, "e2f492820e79bafaa8f7531654c1750e743618a77a7e3701a9d8e2a4234dbb946867c618440e4a6238876a8c2e26b888809988dbd1263ee10c622251c91ee5d4", ""},
+		{4096, crypto.SHA256, "", 4096, "13e9b8848ae484a36acb3f3cac0ceb2f7601e96633d15c92f9bd3dd44e492157", "d67c656e01756650d77717b0839985a056ec28ffe174601d690fc407a2ceffca"},
+		{4096, crypto.SHA256, "", 1024, "721ccec5089314f4cc88e3444ca30f3e709fdbed34de408eb8fe5e5cc44daee1", "a07522d86b57537910a43d216963536aeab84d5579fbffc1337283bb4307ef63"},
+		{600000, crypto.SHA256, "", 4096, "06d5be5c1f84f55006b5826aec720b80e5d95f30e170be7e1d6aae61755f1ae4", "9ccff517573cc39b4e417b2f5608a07c1ce4074fbc29878c1f3d1c0d4be7da50"},
+		{600000, crypto.SHA256, "", 1024, "b8285dadfad866d34cb27b596936ab974f0676d0fd552e9ffba5dc3bfb7baa36", "57f7ea3e0fcb597be14595e4b702fafbb01c278d23bdae27c628e485b715c9b0"},
+		{600000, crypto.SHA512, "73616c74", 4096, "ba6b4098099791db63b10a10e0f073346f5e4fdde105f4ce10968988e5dd2190a0c5dd239503e736fe8a1b9e71ebe8e889f8ca5cbc8fc6a7862736e3bc1514e8", ""},
+		{600000, crypto.SHA1, "73616c74", 4096, "", "2ae20e592fe2c8532b62872c313eb586547c8120"},
+	}
+	for _, want := range xwant {
+		data := make([]byte, want.size)
+		for i := range data {
+			data[i] = byte(i % 251)
+		}
+		file := filepath.Join(dir, "file")
+		if err := os.WriteFile(file, data, 0o644); err != nil {
+			t.Fatal(err)
+		}
+		chosen = []crypto.Hash{want.hash}
+		opts.verityBlockSize = want.blockSize
+		opts.veritySalt, _ = hex.DecodeString(want.salt)
+
+		for _, test := range []struct {
+			name string
+			f    func(string, []*Checksum) (*Checksums, error)
+			want string
+		}{
+			{"hashFSVerity", hashFSVerity, want.fsverity},
+			{"hashDMVerity", hashDMVerity, want.dmverity},
+		} {
+			if test.want == "" {
+				continue
+			}
+			got, err := test.f(file, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if sum := hex.EncodeToString(got.checksums[0].sum); sum != test.want {
+				t.Errorf("%s(%d, %s, %q, %d) got %s; want %s", test.name, want.size, algorithms[want.hash].name, want.salt, want.blockSize, sum, test.want)
+			}
+		}
+	}
+
+	// dm-verity needs at least one data block
+	chosen = []crypto.Hash{crypto.SHA256}
+	opts.verityBlockSize = 4096
+	if _, err := hashDMVerity(filepath.Join(dir, "file"), nil); err != nil {
+		t.Fatal(err)
+	}
+	small := filepath.Join(dir, "small")
+	if err := os.WriteFile(small, []byte("small"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := hashDMVerity(small, nil); err == nil {
+		t.Error("hashDMVerity() on a partial block should fail")
+	}
+}